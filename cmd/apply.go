@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/herringbonedev/hbctl/internal/local"
+	"github.com/spf13/cobra"
+)
+
+func applyCommand() *cobra.Command {
+	var file string
+	var prune bool
+	var dryRun bool
+	var readyTimeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Converge the local stack to a declarative stack file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if strings.TrimSpace(file) == "" {
+				return fmt.Errorf("-f is required")
+			}
+			return local.Apply(local.ApplyOptions{
+				Project:      projectName,
+				SecretsDir:   secretsDirOverride,
+				File:         strings.TrimSpace(file),
+				Prune:        prune,
+				DryRun:       dryRun,
+				ReadyTimeout: readyTimeout,
+			})
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "Stack file to apply")
+	cmd.Flags().BoolVar(&prune, "prune", false, "Also remove running elements and receivers the stack file does not list")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the plan without changing anything")
+	cmd.Flags().DurationVar(&readyTimeout, "ready-timeout", local.DefaultReadyTimeout, "How long to wait for each element's readiness probe; 0 disables the wait")
+	return cmd
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/herringbonedev/hbctl/internal/local"
+	"github.com/spf13/cobra"
+)
+
+func diffCommand() *cobra.Command {
+	var element string
+	var unit string
+	var enterprise bool
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Show running containers that drifted from the current compose files and .env",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return local.Diff(local.DiffOptions{
+				Project:    projectName,
+				Element:    strings.TrimSpace(element),
+				Unit:       strings.TrimSpace(unit),
+				Enterprise: enterprise,
+				JSON:       asJSON,
+				Out:        cmd.OutOrStdout(),
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&element, "element", "", "Element to compare")
+	cmd.Flags().StringVar(&unit, "unit", "", "Unit to compare")
+	cmd.Flags().BoolVar(&enterprise, "enterprise", false, "Include enterprise services")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Output the drift report as JSON")
+	return cmd
+}
@@ -50,6 +50,7 @@ func elementsCommand() *cobra.Command {
 
 			if wide {
 				ui.FHeader(cmd.OutOrStdout(), "Herringbone elements")
+				ui.FKeyValues(cmd.OutOrStdout(), [][2]string{{"catalog", units.CatalogSource()}})
 				rows := make([][]string, 0, len(out))
 				for index, element := range out {
 					rows = append(rows, []string{fmt.Sprintf("%d", index+1), element.Name, element.Unit, element.Description})
@@ -70,6 +71,7 @@ func elementsCommand() *cobra.Command {
 			sort.Strings(unitNames)
 
 			ui.FHeader(cmd.OutOrStdout(), "Herringbone elements")
+			ui.FKeyValues(cmd.OutOrStdout(), [][2]string{{"catalog", units.CatalogSource()}})
 			for _, unitName := range unitNames {
 				ui.FSection(cmd.OutOrStdout(), unitName)
 				rows := make([][]string, 0, len(grouped[unitName]))
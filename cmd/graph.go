@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/herringbonedev/hbctl/internal/local"
+	"github.com/spf13/cobra"
+)
+
+func graphCommand() *cobra.Command {
+	var unit string
+	var element string
+	var enterprise bool
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Show the element dependency graph used for start and stop ordering",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return local.Graph(local.GraphOptions{
+				Unit:       strings.TrimSpace(unit),
+				Element:    strings.TrimSpace(element),
+				Enterprise: enterprise,
+				Format:     format,
+				Out:        cmd.OutOrStdout(),
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&unit, "unit", "", "Limit the graph to a unit and its dependencies")
+	cmd.Flags().StringVar(&element, "element", "", "Limit the graph to an element and its dependencies")
+	cmd.Flags().BoolVar(&enterprise, "enterprise", false, "Include enterprise services")
+	cmd.Flags().StringVar(&format, "format", "table", "Output format: table, dot, mermaid, or json")
+	return cmd
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/herringbonedev/hbctl/internal/local"
+	"github.com/spf13/cobra"
+)
+
+func imagesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "images",
+		Aliases: []string{"image"},
+		Short:   "List, pre-pull, prune, or bundle Herringbone images",
+	}
+	cmd.AddCommand(imagesListCommand())
+	cmd.AddCommand(imagesPullCommand())
+	cmd.AddCommand(imagesPruneCommand())
+	cmd.AddCommand(imagesSaveCommand())
+	cmd.AddCommand(imagesLoadCommand())
+	return cmd
+}
+
+func imagesListCommand() *cobra.Command {
+	var unit string
+	var enterprise bool
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Show images referenced by the compose files with tag, digest, size, and the elements using them",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return local.ListImages(local.ImagesOptions{
+				Project:    projectName,
+				Unit:       strings.TrimSpace(unit),
+				Enterprise: enterprise,
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&unit, "unit", "", "Only show images for this unit")
+	cmd.Flags().BoolVar(&enterprise, "enterprise", false, "Include enterprise services")
+	return cmd
+}
+
+func imagesPullCommand() *cobra.Command {
+	var unit string
+	var enterprise bool
+	var parallel int
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "pull",
+		Short: "Pre-pull images for a unit or the full stack without recreating containers",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return local.PullImages(local.ImagesOptions{
+				Project:    projectName,
+				Unit:       strings.TrimSpace(unit),
+				Enterprise: enterprise,
+				Parallel:   parallel,
+				DryRun:     dryRun,
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&unit, "unit", "", "Only pull images for this unit; defaults to the full stack")
+	cmd.Flags().BoolVar(&enterprise, "enterprise", false, "Include enterprise services")
+	cmd.Flags().IntVar(&parallel, "parallel", local.DefaultImagePullParallel, "Number of images to pull at once")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the docker pull commands without running them")
+	return cmd
+}
+
+func imagesPruneCommand() *cobra.Command {
+	var keepArchived bool
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove Herringbone images that no container uses and the compose files do not reference",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := local.ImagesOptions{
+				Project: projectName,
+				DryRun:  dryRun,
+			}
+			if keepArchived {
+				opts.ArchiveRoot = releaseArchiveRoot
+			}
+			return local.PruneImages(opts)
+		},
+	}
+
+	cmd.Flags().BoolVar(&keepArchived, "keep-archived", false, "Also keep images referenced by compose directories archived under .hbctl/archive")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show which images would be removed")
+	return cmd
+}
+
+func imagesSaveCommand() *cobra.Command {
+	var all bool
+	var unit string
+	var enterprise bool
+	var models bool
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "save",
+		Short: "Save the stack's images, and optionally the tuner model weights, to a bundle for offline hosts",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			unit = strings.TrimSpace(unit)
+			if all == (unit != "") {
+				return fmt.Errorf("pass either --all or --unit")
+			}
+			return local.SaveImageBundle(local.ImagesOptions{
+				Project:    projectName,
+				Unit:       unit,
+				Enterprise: enterprise,
+				Models:     models,
+				Bundle:     strings.TrimSpace(output),
+			})
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "Save the images of the full stack")
+	cmd.Flags().StringVar(&unit, "unit", "", "Save only the images of this unit")
+	cmd.Flags().BoolVar(&enterprise, "enterprise", false, "Include enterprise services")
+	cmd.Flags().BoolVar(&models, "models", false, "Also save the ollama image and the configured fingerprint tuner model weights")
+	cmd.Flags().StringVarP(&output, "output", "o", "herringbone-images.tar.zst", "Bundle file to write")
+	return cmd
+}
+
+func imagesLoadCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "load <bundle>",
+		Short: "Load an image bundle written by hbctl images save and verify it against its manifest",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return local.LoadImageBundle(local.ImagesOptions{
+				Project: projectName,
+				Bundle:  strings.TrimSpace(args[0]),
+			})
+		},
+	}
+}
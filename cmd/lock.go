@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/herringbonedev/hbctl/internal/local"
+	"github.com/spf13/cobra"
+)
+
+func lockCommand() *cobra.Command {
+	var update bool
+	var element string
+	var enterprise bool
+	var noPull bool
+
+	cmd := &cobra.Command{
+		Use:   "lock",
+		Short: "Pin every compose image to its registry digest in " + local.LockFileName,
+		Long: "Resolve the images of the active compose files to registry digests and write them to " + local.LockFileName + ".\n" +
+			"start, restart, and upgrade run the pinned digests through a generated compose override while the lock file exists.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return local.Lock(local.LockOptions{
+				Project:    projectName,
+				Enterprise: enterprise,
+				Element:    strings.TrimSpace(element),
+				Update:     update,
+				Pull:       !noPull,
+			})
+		},
+	}
+
+	cmd.Flags().BoolVar(&update, "update", false, "Re-resolve existing pins instead of keeping them")
+	cmd.Flags().StringVar(&element, "element", "", "Only lock this element's services")
+	cmd.Flags().BoolVar(&enterprise, "enterprise", false, "Include enterprise services")
+	cmd.Flags().BoolVar(&noPull, "no-pull", false, "Resolve digests from local images without pulling")
+	cmd.AddCommand(lockVerifyCommand())
+	return cmd
+}
+
+func lockVerifyCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify",
+		Short: "Report running containers that are not on their pinned digest",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return local.VerifyLock(projectName)
+		},
+	}
+}
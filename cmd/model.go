@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
 
 	"github.com/herringbonedev/hbctl/internal/local"
@@ -70,7 +69,7 @@ func modelUseCommand() *cobra.Command {
 		} else {
 			ui.FSuccess(cmd.OutOrStdout(), "Model already available: %s", model)
 		}
-		if err := saveModelEnv(model); err != nil {
+		if err := local.SaveFingerprintTunerModel(model); err != nil {
 			return err
 		}
 		ui.FSuccess(cmd.OutOrStdout(), "Saved fingerprint tuner model: %s", model)
@@ -130,41 +129,6 @@ func ollamaContainerID() (string, error) {
 	return id, nil
 }
 
-func saveModelEnv(model string) error {
-	path := filepath.Join(".", ".env")
-	content, _ := os.ReadFile(path)
-	lines := strings.Split(string(content), "\n")
-	wanted := map[string]string{
-		"FINGERPRINT_TUNER_LLM_MODEL": model,
-		"OLLAMA_MODEL":                model,
-	}
-	seen := map[string]bool{}
-	out := make([]string, 0, len(lines)+len(wanted))
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		replaced := false
-		for key, value := range wanted {
-			if strings.HasPrefix(trimmed, key+"=") {
-				if !seen[key] {
-					out = append(out, key+"="+value)
-					seen[key] = true
-				}
-				replaced = true
-				break
-			}
-		}
-		if !replaced {
-			out = append(out, line)
-		}
-	}
-	for key, value := range wanted {
-		if !seen[key] {
-			out = append(out, key+"="+value)
-		}
-	}
-	return os.WriteFile(path, []byte(strings.TrimRight(strings.Join(out, "\n"), "\n")+"\n"), 0o644)
-}
-
 func readEnvModel() string {
 	content, err := os.ReadFile(".env")
 	if err != nil {
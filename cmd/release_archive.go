@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/herringbonedev/hbctl/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// releaseArchiveRoot holds the snapshots archiveCurrentDirectory takes before
+// a release is staged. Each snapshot is named <tag>-<YYYYMMDD-HHMMSS>, where
+// tag is the release that replaced the archived files.
+var releaseArchiveRoot = filepath.Join(".hbctl", "archive")
+
+const releaseArchiveStampLayout = "20060102-150405"
+
+type releaseArchive struct {
+	Name  string
+	Path  string
+	Tag   string
+	Time  time.Time
+	Size  int64
+	Files int
+}
+
+func upgradeArchivesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "archives",
+		Short: "List or prune compose directory snapshots taken before release staging",
+	}
+	cmd.AddCommand(upgradeArchivesListCommand())
+	cmd.AddCommand(upgradeArchivesPruneCommand())
+	return cmd
+}
+
+func upgradeArchivesListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List archived compose directories, newest first",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			archives, err := listReleaseArchives()
+			if err != nil {
+				return err
+			}
+			printReleaseArchives(cmd.OutOrStdout(), archives)
+			return nil
+		},
+	}
+}
+
+func upgradeArchivesPruneCommand() *cobra.Command {
+	var keep int
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove all but the newest archives",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if keep < 0 {
+				return fmt.Errorf("--keep must be zero or greater")
+			}
+			archives, err := listReleaseArchives()
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			ui.FHeader(out, "Herringbone archive prune")
+			ui.FKeyValues(out, [][2]string{{"archives", strconv.Itoa(len(archives))}, {"keep", strconv.Itoa(keep)}})
+			if len(archives) <= keep {
+				ui.FSuccess(out, "Nothing to prune")
+				return nil
+			}
+			var freed int64
+			for _, archive := range archives[keep:] {
+				if dryRun {
+					ui.FCommand(out, "rm -rf %s", archive.Path)
+					continue
+				}
+				if err := os.RemoveAll(archive.Path); err != nil {
+					return fmt.Errorf("failed to remove %s: %w", archive.Path, err)
+				}
+				freed += archive.Size
+				ui.FSuccess(out, "Removed %s", archive.Name)
+			}
+			if dryRun {
+				ui.FInfo(out, "Dry run: %d archive(s) would be removed", len(archives)-keep)
+				return nil
+			}
+			ui.FSuccess(out, "Pruned %d archive(s), freed %s", len(archives)-keep, ui.Size(freed))
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&keep, "keep", 3, "Number of newest archives to keep")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show which archives would be removed")
+	return cmd
+}
+
+// listReleaseArchives returns the snapshots under .hbctl/archive, newest first.
+func listReleaseArchives() ([]releaseArchive, error) {
+	entries, err := os.ReadDir(releaseArchiveRoot)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	archives := []releaseArchive{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		archive := releaseArchive{Name: entry.Name(), Path: filepath.Join(releaseArchiveRoot, entry.Name()), Tag: entry.Name()}
+		if len(archive.Name) > len(releaseArchiveStampLayout)+1 {
+			split := len(archive.Name) - len(releaseArchiveStampLayout)
+			if stamp, err := time.ParseInLocation(releaseArchiveStampLayout, archive.Name[split:], time.Local); err == nil && archive.Name[split-1] == '-' {
+				archive.Tag = archive.Name[:split-1]
+				archive.Time = stamp
+			}
+		}
+		if archive.Time.IsZero() {
+			if info, err := entry.Info(); err == nil {
+				archive.Time = info.ModTime()
+			}
+		}
+		_ = filepath.WalkDir(archive.Path, func(path string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				archive.Size += info.Size()
+				archive.Files++
+			}
+			return nil
+		})
+		archives = append(archives, archive)
+	}
+	sort.Slice(archives, func(i, j int) bool {
+		return archives[i].Time.After(archives[j].Time)
+	})
+	return archives, nil
+}
+
+func printReleaseArchives(w io.Writer, archives []releaseArchive) {
+	ui.FHeader(w, "Herringbone archives")
+	ui.FKeyValues(w, [][2]string{{"directory", releaseArchiveRoot}, {"count", strconv.Itoa(len(archives))}})
+	if len(archives) == 0 {
+		ui.FSkip(w, "No archives found")
+		return
+	}
+	rows := make([][]string, 0, len(archives))
+	for i, archive := range archives {
+		name := archive.Name
+		if i == 0 {
+			name += " (previous)"
+		}
+		rows = append(rows, []string{name, archive.Tag, archive.Time.Format("2006-01-02 15:04:05"), ui.Size(archive.Size), strconv.Itoa(archive.Files)})
+	}
+	ui.FTable(w, []string{"ARCHIVE", "REPLACED BY", "ARCHIVED", "SIZE", "FILES"}, rows)
+}
+
+// rollbackReleaseArchive restores an archived compose directory. The files
+// currently in place are archived first so the rollback can itself be undone,
+// and secrets are never touched.
+func rollbackReleaseArchive(name string) error {
+	ui.Header("Herringbone release rollback")
+
+	archives, err := listReleaseArchives()
+	if err != nil {
+		return err
+	}
+	if len(archives) == 0 {
+		return fmt.Errorf("no archives found in %s", releaseArchiveRoot)
+	}
+	var target *releaseArchive
+	name = strings.TrimSpace(name)
+	if name == "previous" {
+		target = &archives[0]
+	} else {
+		for i := range archives {
+			if archives[i].Name == name || archives[i].Path == filepath.Clean(name) {
+				target = &archives[i]
+				break
+			}
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("archive %q not found; run hbctl upgrade archives list", name)
+	}
+	if !hasComposeFiles(target.Path) {
+		return fmt.Errorf("archive %s does not contain compose files", target.Name)
+	}
+
+	ui.KeyValues([][2]string{
+		{"archive", target.Name},
+		{"archived", target.Time.Format("2006-01-02 15:04:05")},
+		{"files", strconv.Itoa(target.Files)},
+	})
+
+	stamp := time.Now().Format(releaseArchiveStampLayout)
+	snapshot := filepath.Join(releaseArchiveRoot, "rollback-"+stamp)
+	if err := os.MkdirAll(snapshot, 0o755); err != nil {
+		return err
+	}
+	ui.Section("Archive current compose directory")
+	if err := archiveCurrentDirectory(snapshot); err != nil {
+		return err
+	}
+	ui.Success("Archived current files to %s", snapshot)
+
+	ui.Section("Restore archived files")
+	if err := copyReleasePayload(target.Path, "."); err != nil {
+		return err
+	}
+	ui.Success("Restored %s", target.Name)
+	ui.Info("Secrets were preserved. Run hbctl upgrade --all to apply the restored compose files, or use --now next time.")
+	return nil
+}
@@ -0,0 +1,429 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/herringbonedev/hbctl/internal/ui"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	releaseDiffContext  = 3
+	releaseDiffMaxCells = 4_000_000
+)
+
+type releaseComposeService struct {
+	Image       string
+	Environment map[string]bool
+}
+
+// printReleaseDiff compares an extracted release payload with the current
+// compose directory. Paths staging preserves (.hbctl, secrets) are left out on
+// both sides, so the report matches what copyReleasePayload would change.
+func printReleaseDiff(payload string) error {
+	current, err := releaseTreeFiles(".")
+	if err != nil {
+		return err
+	}
+	release, err := releaseTreeFiles(payload)
+	if err != nil {
+		return err
+	}
+
+	ui.Section("Release diff")
+	changes := [][]string{}
+	modified := []string{}
+	added, removed := 0, 0
+	for _, rel := range unionKeys(current, release) {
+		currentPath, inCurrent := current[rel]
+		releasePath, inRelease := release[rel]
+		switch {
+		case !inCurrent:
+			added++
+			changes = append(changes, []string{rel, ui.Green("added")})
+		case !inRelease:
+			removed++
+			changes = append(changes, []string{rel, ui.Red("removed")})
+		default:
+			same, err := sameFileContents(currentPath, releasePath)
+			if err != nil {
+				return err
+			}
+			if !same {
+				modified = append(modified, rel)
+				changes = append(changes, []string{rel, ui.Yellow("modified")})
+			}
+		}
+	}
+	ui.KeyValues([][2]string{
+		{"added", strconv.Itoa(added)},
+		{"removed", strconv.Itoa(removed)},
+		{"modified", strconv.Itoa(len(modified))},
+	})
+	if len(changes) == 0 {
+		ui.Success("The release matches the current compose directory")
+		return nil
+	}
+	ui.Table([]string{"FILE", "CHANGE"}, changes)
+
+	currentServices, err := releaseComposeServices(current)
+	if err != nil {
+		return err
+	}
+	releaseServices, err := releaseComposeServices(release)
+	if err != nil {
+		return err
+	}
+	printReleaseImageChanges(currentServices, releaseServices)
+	if err := printReleaseEnvChanges(current, release, currentServices, releaseServices); err != nil {
+		return err
+	}
+
+	for _, rel := range modified {
+		if !isReleaseDiffText(rel) {
+			continue
+		}
+		before, err := os.ReadFile(current[rel])
+		if err != nil {
+			return err
+		}
+		after, err := os.ReadFile(release[rel])
+		if err != nil {
+			return err
+		}
+		ui.Section("Diff " + rel)
+		printUnifiedDiff(rel, splitDiffLines(before), splitDiffLines(after))
+	}
+	return nil
+}
+
+// releaseTreeFiles maps each regular file under root, by slash-separated
+// relative path, to its full path.
+func releaseTreeFiles(root string) (map[string]string, error) {
+	files := map[string]string{}
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if shouldPreserveDuringReleaseStage(strings.Split(rel, string(os.PathSeparator))[0]) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			files[filepath.ToSlash(rel)] = path
+		}
+		return nil
+	})
+	return files, err
+}
+
+func sameFileContents(a string, b string) (bool, error) {
+	left, err := os.ReadFile(a)
+	if err != nil {
+		return false, err
+	}
+	right, err := os.ReadFile(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(left, right), nil
+}
+
+func isComposeFileName(name string) bool {
+	return (strings.HasPrefix(name, "compose") || strings.HasPrefix(name, "docker-compose")) &&
+		(strings.HasSuffix(name, ".yml") || strings.HasSuffix(name, ".yaml"))
+}
+
+// isReleaseDiffText reports whether a modified file is shown as a unified
+// diff rather than only listed.
+func isReleaseDiffText(rel string) bool {
+	name := filepath.Base(rel)
+	return name == "init-mongo.js" || isComposeFileName(name)
+}
+
+func isEnvFileName(name string) bool {
+	return name == ".env" || strings.HasPrefix(name, ".env.") || strings.HasSuffix(name, ".env")
+}
+
+// releaseComposeServices reads the image and environment keys of every
+// service in the tree's compose files, uninterpolated. A service defined in
+// several files keeps the last image and the union of its keys.
+func releaseComposeServices(files map[string]string) (map[string]releaseComposeService, error) {
+	services := map[string]releaseComposeService{}
+	for _, rel := range unionKeys(files, nil) {
+		if !isComposeFileName(filepath.Base(rel)) {
+			continue
+		}
+		data, err := os.ReadFile(files[rel])
+		if err != nil {
+			return nil, err
+		}
+		var doc struct {
+			Services map[string]struct {
+				Image       string    `yaml:"image"`
+				Environment yaml.Node `yaml:"environment"`
+			} `yaml:"services"`
+		}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", rel, err)
+		}
+		for name, def := range doc.Services {
+			service, ok := services[name]
+			if !ok {
+				service = releaseComposeService{Environment: map[string]bool{}}
+			}
+			if strings.TrimSpace(def.Image) != "" {
+				service.Image = strings.TrimSpace(def.Image)
+			}
+			switch def.Environment.Kind {
+			case yaml.MappingNode:
+				for i := 0; i+1 < len(def.Environment.Content); i += 2 {
+					service.Environment[def.Environment.Content[i].Value] = true
+				}
+			case yaml.SequenceNode:
+				for _, item := range def.Environment.Content {
+					key, _, _ := strings.Cut(item.Value, "=")
+					service.Environment[strings.TrimSpace(key)] = true
+				}
+			}
+			services[name] = service
+		}
+	}
+	return services, nil
+}
+
+func printReleaseImageChanges(current map[string]releaseComposeService, release map[string]releaseComposeService) {
+	rows := [][]string{}
+	for _, name := range unionKeys(current, release) {
+		before, inCurrent := current[name]
+		after, inRelease := release[name]
+		switch {
+		case !inCurrent:
+			rows = append(rows, []string{name, "-", blankDash(after.Image)})
+		case !inRelease:
+			rows = append(rows, []string{name, blankDash(before.Image), "-"})
+		case before.Image != after.Image:
+			rows = append(rows, []string{name, blankDash(before.Image), blankDash(after.Image)})
+		}
+	}
+	ui.Section("Image changes")
+	if len(rows) == 0 {
+		ui.Skip("No service images change")
+		return
+	}
+	ui.Table([]string{"SERVICE", "CURRENT", "RELEASE"}, rows)
+}
+
+// printReleaseEnvChanges lists variables added to or removed from services
+// present in both trees and from .env-style files.
+func printReleaseEnvChanges(currentFiles map[string]string, releaseFiles map[string]string, current map[string]releaseComposeService, release map[string]releaseComposeService) error {
+	rows := [][]string{}
+	addRows := func(scope string, before map[string]bool, after map[string]bool) {
+		for _, key := range unionKeys(before, after) {
+			switch {
+			case !before[key]:
+				rows = append(rows, []string{scope, key, ui.Green("added")})
+			case !after[key]:
+				rows = append(rows, []string{scope, key, ui.Red("removed")})
+			}
+		}
+	}
+
+	for _, name := range unionKeys(current, release) {
+		before, inCurrent := current[name]
+		after, inRelease := release[name]
+		if inCurrent && inRelease {
+			addRows("service "+name, before.Environment, after.Environment)
+		}
+	}
+	for _, rel := range unionKeys(currentFiles, releaseFiles) {
+		if !isEnvFileName(filepath.Base(rel)) {
+			continue
+		}
+		before, err := envFileKeys(currentFiles[rel])
+		if err != nil {
+			return err
+		}
+		after, err := envFileKeys(releaseFiles[rel])
+		if err != nil {
+			return err
+		}
+		addRows(rel, before, after)
+	}
+
+	ui.Section("Environment changes")
+	if len(rows) == 0 {
+		ui.Skip("No environment variables added or removed")
+		return nil
+	}
+	ui.Table([]string{"SCOPE", "VARIABLE", "CHANGE"}, rows)
+	return nil
+}
+
+// envFileKeys returns the variable names set in a dotenv file; an empty path
+// means the file does not exist on that side.
+func envFileKeys(path string) (map[string]bool, error) {
+	keys := map[string]bool{}
+	if path == "" {
+		return keys, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, _, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if ok && strings.TrimSpace(key) != "" {
+			keys[strings.TrimSpace(key)] = true
+		}
+	}
+	return keys, scanner.Err()
+}
+
+func splitDiffLines(data []byte) []string {
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// printUnifiedDiff prints a unified diff of two line slices with
+// releaseDiffContext lines of context around each change.
+func printUnifiedDiff(name string, before []string, after []string) {
+	if len(before)*len(after) > releaseDiffMaxCells {
+		ui.Skip("%s is too large to diff line by line", name)
+		return
+	}
+
+	// lcs[i][j] is the longest common subsequence of before[i:] and after[j:].
+	lcs := make([][]int, len(before)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(after)+1)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			if before[i] == after[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type diffLine struct {
+		op   byte
+		text string
+		old  int
+		new  int
+	}
+	lines := []diffLine{}
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case i < len(before) && j < len(after) && before[i] == after[j]:
+			lines = append(lines, diffLine{' ', before[i], i, j})
+			i++
+			j++
+		case i < len(before) && (j == len(after) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', before[i], i, j})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', after[j], i, j})
+			j++
+		}
+	}
+
+	fmt.Println(ui.Bold("--- current/" + name))
+	fmt.Println(ui.Bold("+++ release/" + name))
+	for start := 0; start < len(lines); {
+		for start < len(lines) && lines[start].op == ' ' {
+			start++
+		}
+		if start == len(lines) {
+			break
+		}
+		from := max(start-releaseDiffContext, 0)
+		end := start
+		for k := start; k < len(lines) && k-end <= 2*releaseDiffContext; k++ {
+			if lines[k].op != ' ' {
+				end = k
+			}
+		}
+		to := min(end+releaseDiffContext+1, len(lines))
+
+		oldCount, newCount := 0, 0
+		for _, line := range lines[from:to] {
+			if line.op != '+' {
+				oldCount++
+			}
+			if line.op != '-' {
+				newCount++
+			}
+		}
+		oldStart, newStart := lines[from].old, lines[from].new
+		if oldCount > 0 {
+			oldStart++
+		}
+		if newCount > 0 {
+			newStart++
+		}
+		fmt.Println(ui.Cyan(fmt.Sprintf("@@ -%d,%d +%d,%d @@", oldStart, oldCount, newStart, newCount)))
+		for _, line := range lines[from:to] {
+			text := string(line.op) + line.text
+			switch line.op {
+			case '+':
+				text = ui.Green(text)
+			case '-':
+				text = ui.Red(text)
+			}
+			fmt.Println(text)
+		}
+		start = to
+	}
+}
+
+func unionKeys[V any](a map[string]V, b map[string]V) []string {
+	seen := map[string]bool{}
+	for key := range a {
+		seen[key] = true
+	}
+	for key := range b {
+		seen[key] = true
+	}
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func blankDash(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/herringbonedev/hbctl/internal/local"
+	"github.com/herringbonedev/hbctl/internal/ui"
+	"gopkg.in/yaml.v3"
+)
+
+// releaseManifestName is the optional file a release payload ships next to
+// its compose files to declare what it needs from hbctl.
+const releaseManifestName = "hbctl-release.yaml"
+
+// releaseManifestVersion is the newest release manifest format this hbctl
+// understands.
+const releaseManifestVersion = 1
+
+type releaseManifest struct {
+	Version int `yaml:"version"`
+	Hbctl   struct {
+		Min string `yaml:"min"`
+		Max string `yaml:"max"`
+	} `yaml:"hbctl"`
+	Services   []releaseManifestService `yaml:"services"`
+	Migrations []string                 `yaml:"migrations"`
+}
+
+type releaseManifestService struct {
+	Name   string   `yaml:"name"`
+	Scopes []string `yaml:"scopes"`
+}
+
+// checkReleaseCompatibility reads the payload's release manifest and compares
+// it with this hbctl. A release that needs a newer hbctl, service identities
+// or scopes missing from BootstrapServices, or Mongo migrations this hbctl
+// does not run is refused with each missing item listed. Running an hbctl
+// newer than the declared maximum only warns.
+func checkReleaseCompatibility(payload string) error {
+	ui.Section("Release compatibility")
+	path := filepath.Join(payload, releaseManifestName)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		ui.Skip("No %s in the release; compatibility not checked", releaseManifestName)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var manifest releaseManifest
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&manifest); err != nil {
+		return fmt.Errorf("invalid release manifest %s: %w", releaseManifestName, err)
+	}
+	if manifest.Version <= 0 {
+		return fmt.Errorf("release manifest %s is missing version", releaseManifestName)
+	}
+	if manifest.Version > releaseManifestVersion {
+		return fmt.Errorf("release manifest %s uses version %d; this hbctl supports up to version %d, upgrade hbctl first", releaseManifestName, manifest.Version, releaseManifestVersion)
+	}
+
+	supported := blankDash(manifest.Hbctl.Min) + " .. " + blankDash(manifest.Hbctl.Max)
+	ui.KeyValues([][2]string{{"manifest", releaseManifestName}, {"hbctl", Version}, {"supported", supported}})
+
+	missing := [][]string{}
+	running, runningOK := parseHbctlVersion(Version)
+	if minimum := strings.TrimSpace(manifest.Hbctl.Min); minimum != "" {
+		want, ok := parseHbctlVersion(minimum)
+		switch {
+		case !ok:
+			return fmt.Errorf("release manifest %s has an invalid hbctl.min %q", releaseManifestName, minimum)
+		case !runningOK:
+			ui.Warn("Cannot compare development build %s with minimum %s", Version, minimum)
+		case compareHbctlVersions(running, want) < 0:
+			missing = append(missing, []string{"hbctl version", fmt.Sprintf("%s or newer (running %s)", minimum, Version)})
+		}
+	}
+	if maximum := strings.TrimSpace(manifest.Hbctl.Max); maximum != "" {
+		want, ok := parseHbctlVersion(maximum)
+		switch {
+		case !ok:
+			return fmt.Errorf("release manifest %s has an invalid hbctl.max %q", releaseManifestName, maximum)
+		case runningOK && compareHbctlVersions(running, want) > 0:
+			ui.Warn("hbctl %s is newer than the %s this release was tested with", Version, maximum)
+		}
+	}
+
+	identities := map[string]local.ServiceIdentity{}
+	for _, identity := range local.BootstrapServices {
+		identities[identity.Name] = identity
+	}
+	for _, service := range manifest.Services {
+		identity, ok := identities[strings.TrimSpace(service.Name)]
+		if !ok {
+			missing = append(missing, []string{"service identity", service.Name})
+			continue
+		}
+		have := map[string]bool{}
+		for _, scope := range identity.Scopes {
+			have[scope] = true
+		}
+		absent := []string{}
+		for _, scope := range service.Scopes {
+			if !have[strings.TrimSpace(scope)] {
+				absent = append(absent, strings.TrimSpace(scope))
+			}
+		}
+		if len(absent) > 0 {
+			missing = append(missing, []string{"service scopes", service.Name + ": " + strings.Join(absent, ", ")})
+		}
+	}
+
+	known := map[string]bool{}
+	for _, migration := range local.MongoMigrationNames() {
+		known[migration] = true
+	}
+	for _, migration := range manifest.Migrations {
+		if !known[strings.TrimSpace(migration)] {
+			missing = append(missing, []string{"mongo migration", migration})
+		}
+	}
+
+	if len(missing) == 0 {
+		ui.Success("This hbctl meets the release requirements")
+		return nil
+	}
+	ui.Table([]string{"MISSING", "REQUIRED"}, missing)
+	return fmt.Errorf("this hbctl (%s) is not compatible with the release: %d requirement(s) missing; upgrade hbctl first", Version, len(missing))
+}
+
+type hbctlVersion struct {
+	channel int
+	parts   [3]int
+}
+
+// parseHbctlVersion accepts versions like v0.7.0, 0.7, alpha-0.6.0, and
+// v0.6.0-alpha.1. For equal numbers, channels order alpha < beta < rc <
+// stable; a number after the channel suffix is ignored.
+func parseHbctlVersion(value string) (hbctlVersion, bool) {
+	value = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(value)), "v")
+	version := hbctlVersion{channel: 3}
+	channels := []string{"alpha", "beta", "rc"}
+	for rank, channel := range channels {
+		if strings.HasPrefix(value, channel+"-") {
+			version.channel = rank
+			value = strings.TrimPrefix(strings.TrimPrefix(value, channel+"-"), "v")
+			break
+		}
+	}
+	if number, suffix, ok := strings.Cut(value, "-"); ok {
+		known := false
+		for rank, channel := range channels {
+			if suffix == channel || strings.HasPrefix(suffix, channel+".") {
+				version.channel = rank
+				known = true
+			}
+		}
+		if !known {
+			return hbctlVersion{}, false
+		}
+		value = number
+	}
+	fields := strings.Split(value, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return hbctlVersion{}, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return hbctlVersion{}, false
+		}
+		version.parts[i] = n
+	}
+	return version, true
+}
+
+func compareHbctlVersions(a hbctlVersion, b hbctlVersion) int {
+	for i := range a.parts {
+		if a.parts[i] != b.parts[i] {
+			return a.parts[i] - b.parts[i]
+		}
+	}
+	return a.channel - b.channel
+}
@@ -16,18 +16,29 @@ import (
 	"github.com/herringbonedev/hbctl/internal/ui"
 )
 
-func stageReleaseFromGitHub(tag string, assetName string, timeout time.Duration) error {
+// releaseStageOptions are shared by the GitHub, file, and directory staging
+// paths.
+type releaseStageOptions struct {
+	AssetName          string
+	Timeout            time.Duration
+	InsecureSkipVerify bool
+	// DiffOnly prints what the release would change and stops before the
+	// current compose directory is archived.
+	DiffOnly bool
+}
+
+func stageReleaseFromGitHub(tag string, opts releaseStageOptions) error {
 	ui.Header("Herringbone release stage")
 	ui.FKeyValues(os.Stdout, [][2]string{{"tag", tag}})
 
-	release, err := fetchHerringboneReleaseByTag(tag, timeout)
+	release, err := fetchHerringboneReleaseByTag(tag, opts.Timeout)
 	if err != nil {
 		return err
 	}
 
-	asset := selectReleaseArchiveAsset(release, assetName)
-	if strings.TrimSpace(assetName) != "" && asset.Name == "" {
-		return fmt.Errorf("release %s does not contain asset %q", tag, assetName)
+	asset := selectReleaseArchiveAsset(release, opts.AssetName)
+	if strings.TrimSpace(opts.AssetName) != "" && asset.Name == "" {
+		return fmt.Errorf("release %s does not contain asset %q", tag, opts.AssetName)
 	}
 	downloadURL := strings.TrimSpace(asset.BrowserDownloadURL)
 	downloadName := strings.TrimSpace(asset.Name)
@@ -42,18 +53,14 @@ func stageReleaseFromGitHub(tag string, assetName string, timeout time.Duration)
 		downloadName = safeReleaseTag(tag) + ".tar.gz"
 	}
 
-	stamp := time.Now().Format("20060102-150405")
-	workRoot := filepath.Join(".hbctl", "releases", safeReleaseTag(tag)+"-"+stamp)
-	downloadDir := filepath.Join(workRoot, "download")
-	extractDir := filepath.Join(workRoot, "extract")
-	archiveDir := filepath.Join(".hbctl", "archive", safeReleaseTag(tag)+"-"+stamp)
-	if err := os.MkdirAll(downloadDir, 0o755); err != nil {
-		return err
-	}
-	if err := os.MkdirAll(extractDir, 0o755); err != nil {
+	stamp := time.Now().Format(releaseArchiveStampLayout)
+	workRoot, cleanup, err := releaseWorkRoot(tag, stamp, opts.DiffOnly)
+	if err != nil {
 		return err
 	}
-	if err := os.MkdirAll(archiveDir, 0o755); err != nil {
+	defer cleanup()
+	downloadDir := filepath.Join(workRoot, "download")
+	if err := os.MkdirAll(downloadDir, 0o755); err != nil {
 		return err
 	}
 
@@ -64,21 +71,178 @@ func stageReleaseFromGitHub(tag string, assetName string, timeout time.Duration)
 	} else {
 		ui.KeyValues([][2]string{{"asset", "source tarball"}, {"url", downloadURL}})
 	}
-	if err := downloadReleaseArchive(downloadURL, archivePath, timeout); err != nil {
+	if err := downloadReleaseArchive(downloadURL, archivePath, opts.Timeout); err != nil {
 		return err
 	}
 	ui.Success("Downloaded %s", archivePath)
 
+	verification, verifyErr := verifyGitHubRelease(release, archivePath, downloadName, downloadDir, opts.Timeout)
+	if err := reportReleaseVerification(verification, verifyErr, opts.InsecureSkipVerify); err != nil {
+		return err
+	}
+
 	ui.Section("Extract release")
+	extractDir := filepath.Join(workRoot, "extract")
+	if err := os.MkdirAll(extractDir, 0o755); err != nil {
+		return err
+	}
 	if err := extractReleaseArchive(archivePath, extractDir); err != nil {
 		return err
 	}
+	return installStagedRelease(tag, stamp, extractDir, opts)
+}
+
+// stageReleaseFromFile stages a release archive that is already on disk, for
+// hosts that cannot reach GitHub. A SHA256SUMS file next to the archive is
+// verified the same way as a downloaded one.
+func stageReleaseFromFile(path string, opts releaseStageOptions) error {
+	ui.Header("Herringbone release stage")
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory; use --release-dir for an unpacked payload", path)
+	}
+	lower := strings.ToLower(path)
+	if !isTarArchiveName(lower) && !strings.HasSuffix(lower, ".zip") {
+		return fmt.Errorf("%s is not a .tar.gz, .tgz, .tar, or .zip archive", path)
+	}
+	label := releaseLabelFromFile(path)
+	ui.KeyValues([][2]string{{"file", path}, {"release", label}})
+
+	sumsPath := filepath.Join(filepath.Dir(path), releaseSumsName)
+	if _, err := os.Stat(sumsPath); err == nil {
+		signaturePath := ""
+		for _, suffix := range releaseSignatureSuffixes {
+			if _, err := os.Stat(sumsPath + suffix); err == nil {
+				signaturePath = sumsPath + suffix
+				break
+			}
+		}
+		var verification releaseVerification
+		var verifyErr error
+		if signaturePath == "" {
+			verification = releaseVerification{Checksum: "not checked", Signature: "missing"}
+			verifyErr = fmt.Errorf("no %s.minisig or %s.sig next to %s", releaseSumsName, releaseSumsName, sumsPath)
+		} else {
+			verification, verifyErr = verifyReleaseFiles(path, filepath.Base(path), sumsPath, signaturePath)
+		}
+		if err := reportReleaseVerification(verification, verifyErr, opts.InsecureSkipVerify); err != nil {
+			return err
+		}
+	} else {
+		ui.Section("Verify release")
+		ui.Skip("No %s next to %s; checksum not verified", releaseSumsName, filepath.Base(path))
+	}
+
+	stamp := time.Now().Format(releaseArchiveStampLayout)
+	workRoot, cleanup, err := releaseWorkRoot(label, stamp, opts.DiffOnly)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	extractDir := filepath.Join(workRoot, "extract")
+	if err := os.MkdirAll(extractDir, 0o755); err != nil {
+		return err
+	}
+	ui.Section("Extract release")
+	if err := extractReleaseArchive(path, extractDir); err != nil {
+		return err
+	}
+	return installStagedRelease(label, stamp, extractDir, opts)
+}
+
+// stageReleaseFromDir stages an unpacked release payload. The directory is
+// copied under .hbctl first, so a payload kept inside the compose directory
+// survives the archive step.
+func stageReleaseFromDir(dir string, opts releaseStageOptions) error {
+	ui.Header("Herringbone release stage")
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory; use --release-file for an archive", dir)
+	}
+	source, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if source == cwd || strings.HasPrefix(cwd, source+string(os.PathSeparator)) {
+		return fmt.Errorf("--release-dir must not be or contain the current compose directory")
+	}
+	label := filepath.Base(source)
+	ui.KeyValues([][2]string{{"directory", dir}, {"release", label}})
+
+	ui.Section("Verify release")
+	ui.Skip("Unpacked directories are not checksum verified")
+
+	if opts.DiffOnly {
+		// Nothing is archived or installed, so the payload is read in place.
+		return installStagedRelease(label, "", source, opts)
+	}
+
+	stamp := time.Now().Format(releaseArchiveStampLayout)
+	extractDir := filepath.Join(".hbctl", "releases", safeReleaseTag(label)+"-"+stamp, "extract")
+	if err := os.MkdirAll(extractDir, 0o755); err != nil {
+		return err
+	}
+	ui.Section("Copy release")
+	if err := copyReleasePayload(source, extractDir); err != nil {
+		return err
+	}
+	return installStagedRelease(label, stamp, extractDir, opts)
+}
+
+// releaseWorkRoot returns the directory a release is downloaded and extracted
+// into. A --diff run uses a temporary directory that cleanup removes, so it
+// leaves nothing under .hbctl/releases.
+func releaseWorkRoot(label string, stamp string, diffOnly bool) (string, func(), error) {
+	if !diffOnly {
+		return filepath.Join(".hbctl", "releases", safeReleaseTag(label)+"-"+stamp), func() {}, nil
+	}
+	root, err := os.MkdirTemp("", "hbctl-release-diff-")
+	if err != nil {
+		return "", nil, err
+	}
+	ui.Info("Using temporary directory %s for --diff; it is removed afterwards", root)
+	return root, func() { _ = os.RemoveAll(root) }, nil
+}
+
+// installStagedRelease finds the compose payload in extractDir, archives the
+// current compose directory, and installs the payload in its place. With
+// DiffOnly it only reports what would change.
+func installStagedRelease(label string, stamp string, extractDir string, opts releaseStageOptions) error {
 	payload, err := findDockerPayloadDir(extractDir)
 	if err != nil {
 		return err
 	}
 	ui.KeyValues([][2]string{{"payload", payload}})
 
+	if err := checkReleaseCompatibility(payload); err != nil {
+		if !opts.DiffOnly {
+			return err
+		}
+		ui.Warn("%v", err)
+	}
+
+	if opts.DiffOnly {
+		if err := printReleaseDiff(payload); err != nil {
+			return err
+		}
+		ui.Info("Nothing was staged. Run the same command without --diff to stage %s.", label)
+		return nil
+	}
+
+	archiveDir := filepath.Join(releaseArchiveRoot, safeReleaseTag(label)+"-"+stamp)
+	if err := os.MkdirAll(archiveDir, 0o755); err != nil {
+		return err
+	}
 	ui.Section("Archive current compose directory")
 	if err := archiveCurrentDirectory(archiveDir); err != nil {
 		return err
@@ -89,11 +253,24 @@ func stageReleaseFromGitHub(tag string, assetName string, timeout time.Duration)
 	if err := copyReleasePayload(payload, "."); err != nil {
 		return err
 	}
-	ui.Success("Release %s staged", tag)
+	ui.Success("Release %s staged", label)
 	ui.Info("Secrets were preserved. Run hbctl upgrade --all to apply the staged compose/images, or use --now next time.")
 	return nil
 }
 
+// releaseLabelFromFile names a local release after its archive file, without
+// the archive extension.
+func releaseLabelFromFile(path string) string {
+	name := filepath.Base(path)
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(lower, ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return name
+}
+
 func selectReleaseArchiveAsset(release githubRelease, requested string) githubReleaseAsset {
 	requested = strings.TrimSpace(requested)
 	if requested != "" {
@@ -0,0 +1,325 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/herringbonedev/hbctl/internal/config"
+	"github.com/herringbonedev/hbctl/internal/ui"
+	"golang.org/x/crypto/blake2b"
+)
+
+// ReleasePublicKey is the minisign (or raw base64 ed25519) public key release
+// SHA256SUMS files are signed with. Release builds inject it with:
+//
+//	go build -ldflags="-X github.com/herringbonedev/hbctl/cmd.ReleasePublicKey=RWQ..."
+//
+// HBCTL_RELEASE_PUBKEY or ~/.hbctl/release.pub override it locally.
+var ReleasePublicKey = ""
+
+const (
+	releaseSumsName       = "SHA256SUMS"
+	releasePublicKeyEnv   = "HBCTL_RELEASE_PUBKEY"
+	releasePublicKeyFile  = "release.pub"
+	minisignAlgorithm     = "Ed"
+	minisignHashAlgorithm = "ED"
+)
+
+var releaseSignatureSuffixes = []string{".minisig", ".sig"}
+
+type releasePublicKey struct {
+	Key    ed25519.PublicKey
+	KeyID  []byte
+	Source string
+}
+
+type releaseVerification struct {
+	SHA256    string
+	Checksum  string
+	Signature string
+	KeyID     string
+	KeySource string
+	Comment   string
+}
+
+// verifyGitHubRelease downloads the release's SHA256SUMS and its detached
+// signature next to the archive and verifies both.
+func verifyGitHubRelease(release githubRelease, archivePath string, archiveName string, downloadDir string, timeout time.Duration) (releaseVerification, error) {
+	result := releaseVerification{Checksum: "not checked", Signature: "not checked"}
+
+	var sums, signature githubReleaseAsset
+	for _, asset := range release.Assets {
+		if asset.Name == releaseSumsName {
+			sums = asset
+		}
+	}
+	for _, suffix := range releaseSignatureSuffixes {
+		for _, asset := range release.Assets {
+			if signature.Name == "" && asset.Name == releaseSumsName+suffix {
+				signature = asset
+			}
+		}
+	}
+	if sums.Name == "" {
+		return result, fmt.Errorf("release %s has no %s asset", release.TagName, releaseSumsName)
+	}
+	if signature.Name == "" {
+		return result, fmt.Errorf("release %s has no %s.minisig or %s.sig asset", release.TagName, releaseSumsName, releaseSumsName)
+	}
+
+	sumsPath := filepath.Join(downloadDir, sums.Name)
+	if err := downloadReleaseArchive(sums.BrowserDownloadURL, sumsPath, timeout); err != nil {
+		return result, err
+	}
+	signaturePath := filepath.Join(downloadDir, signature.Name)
+	if err := downloadReleaseArchive(signature.BrowserDownloadURL, signaturePath, timeout); err != nil {
+		return result, err
+	}
+	return verifyReleaseFiles(archivePath, archiveName, sumsPath, signaturePath)
+}
+
+// verifyReleaseFiles checks the SHA256SUMS signature first and only then
+// trusts the checksum it lists for archiveName.
+func verifyReleaseFiles(archivePath string, archiveName string, sumsPath string, signaturePath string) (releaseVerification, error) {
+	result := releaseVerification{Checksum: "not checked", Signature: "not checked"}
+
+	digest, err := fileSHA256(archivePath)
+	if err != nil {
+		return result, err
+	}
+	result.SHA256 = digest
+
+	key, err := loadReleasePublicKey()
+	if err != nil {
+		return result, err
+	}
+	result.KeySource = key.Source
+	if len(key.KeyID) > 0 {
+		result.KeyID = minisignKeyID(key.KeyID)
+	}
+
+	sums, err := os.ReadFile(sumsPath)
+	if err != nil {
+		return result, err
+	}
+	signature, err := os.ReadFile(signaturePath)
+	if err != nil {
+		return result, err
+	}
+	comment, err := verifyReleaseSignature(key, sums, signature)
+	if err != nil {
+		result.Signature = "invalid"
+		return result, fmt.Errorf("%s signature: %w", filepath.Base(sumsPath), err)
+	}
+	result.Signature = "valid"
+	result.Comment = comment
+
+	want, err := releaseSumFor(sums, archiveName)
+	if err != nil {
+		result.Checksum = "missing"
+		return result, err
+	}
+	if !strings.EqualFold(want, digest) {
+		result.Checksum = "mismatch"
+		return result, fmt.Errorf("%s checksum mismatch: %s lists %s, the archive is %s", archiveName, releaseSumsName, want, digest)
+	}
+	result.Checksum = "match"
+	return result, nil
+}
+
+// reportReleaseVerification prints the verification result. A failure stops
+// staging unless insecureSkipVerify is set, in which case it is only warned
+// about.
+func reportReleaseVerification(result releaseVerification, verifyErr error, insecureSkipVerify bool) error {
+	ui.Section("Verify release")
+	rows := [][2]string{}
+	if result.SHA256 != "" {
+		rows = append(rows, [2]string{"sha256", result.SHA256})
+	}
+	rows = append(rows, [2]string{"checksum", result.Checksum}, [2]string{"signature", result.Signature})
+	if result.KeyID != "" {
+		rows = append(rows, [2]string{"key id", result.KeyID})
+	}
+	if result.KeySource != "" {
+		rows = append(rows, [2]string{"key source", result.KeySource})
+	}
+	if result.Comment != "" {
+		rows = append(rows, [2]string{"trusted comment", result.Comment})
+	}
+	ui.KeyValues(rows)
+
+	if verifyErr == nil {
+		ui.Success("Release verified")
+		return nil
+	}
+	if insecureSkipVerify {
+		ui.Warn("Release verification failed: %v", verifyErr)
+		ui.Warn("Continuing because --insecure-skip-verify was passed")
+		return nil
+	}
+	return fmt.Errorf("release verification failed: %w; pass --insecure-skip-verify to stage it anyway", verifyErr)
+}
+
+// loadReleasePublicKey returns the first key found in HBCTL_RELEASE_PUBKEY
+// (key text or a path to a key file), ~/.hbctl/release.pub, or the key
+// compiled into hbctl.
+func loadReleasePublicKey() (releasePublicKey, error) {
+	if value := strings.TrimSpace(os.Getenv(releasePublicKeyEnv)); value != "" {
+		source := releasePublicKeyEnv
+		if data, err := os.ReadFile(value); err == nil {
+			value = string(data)
+			source = releasePublicKeyEnv + " (" + filepath.Clean(strings.TrimSpace(os.Getenv(releasePublicKeyEnv))) + ")"
+		}
+		return parseReleasePublicKey(value, source)
+	}
+
+	if dir, err := config.HbctlDir(); err == nil {
+		path := filepath.Join(dir, releasePublicKeyFile)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			return parseReleasePublicKey(string(data), path)
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return releasePublicKey{}, err
+		}
+	}
+
+	if strings.TrimSpace(ReleasePublicKey) != "" {
+		return parseReleasePublicKey(ReleasePublicKey, "built in")
+	}
+	return releasePublicKey{}, fmt.Errorf("no release public key configured; set %s or write the minisign public key to ~/.hbctl/%s", releasePublicKeyEnv, releasePublicKeyFile)
+}
+
+// parseReleasePublicKey accepts a minisign public key file (with or without
+// its untrusted comment line) or a bare base64 ed25519 key.
+func parseReleasePublicKey(text string, source string) (releasePublicKey, error) {
+	line := ""
+	for _, candidate := range strings.Split(text, "\n") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "" || strings.HasPrefix(candidate, "untrusted comment:") {
+			continue
+		}
+		line = candidate
+		break
+	}
+	raw, err := base64.StdEncoding.DecodeString(line)
+	if err != nil {
+		return releasePublicKey{}, fmt.Errorf("invalid release public key from %s: %w", source, err)
+	}
+	switch {
+	case len(raw) == 2+8+ed25519.PublicKeySize && string(raw[:2]) == minisignAlgorithm:
+		return releasePublicKey{Key: ed25519.PublicKey(raw[10:]), KeyID: raw[2:10], Source: source}, nil
+	case len(raw) == ed25519.PublicKeySize:
+		return releasePublicKey{Key: ed25519.PublicKey(raw), Source: source}, nil
+	default:
+		return releasePublicKey{}, fmt.Errorf("invalid release public key from %s: expected a minisign or ed25519 key", source)
+	}
+}
+
+// verifyReleaseSignature checks a minisign signature (legacy or prehashed)
+// including its trusted comment, or a raw ed25519 signature in base64 or
+// binary form. It returns the trusted comment when there is one.
+func verifyReleaseSignature(key releasePublicKey, data []byte, signature []byte) (string, error) {
+	text := strings.TrimSpace(string(signature))
+	if !strings.HasPrefix(text, "untrusted comment:") {
+		sig, err := base64.StdEncoding.DecodeString(text)
+		if err != nil || len(sig) != ed25519.SignatureSize {
+			sig = signature
+		}
+		if len(sig) != ed25519.SignatureSize {
+			return "", fmt.Errorf("unrecognized signature format")
+		}
+		if !ed25519.Verify(key.Key, data, sig) {
+			return "", fmt.Errorf("signature does not match")
+		}
+		return "", nil
+	}
+
+	lines := []string{}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSpace(scanner.Text()))
+	}
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return "", fmt.Errorf("malformed minisign signature")
+	}
+	sig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return "", fmt.Errorf("malformed minisign signature")
+	}
+	if len(key.KeyID) > 0 && !bytes.Equal(sig[2:10], key.KeyID) {
+		return "", fmt.Errorf("signed with key %s, expected %s", minisignKeyID(sig[2:10]), minisignKeyID(key.KeyID))
+	}
+
+	message := data
+	switch string(sig[:2]) {
+	case minisignAlgorithm:
+	case minisignHashAlgorithm:
+		sum := blake2b.Sum512(data)
+		message = sum[:]
+	default:
+		return "", fmt.Errorf("unsupported minisign algorithm %q", string(sig[:2]))
+	}
+	if !ed25519.Verify(key.Key, message, sig[10:]) {
+		return "", fmt.Errorf("signature does not match")
+	}
+
+	comment := strings.TrimPrefix(lines[2], "trusted comment: ")
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return "", fmt.Errorf("malformed minisign global signature")
+	}
+	if !ed25519.Verify(key.Key, append(append([]byte{}, sig[10:]...), comment...), global) {
+		return "", fmt.Errorf("trusted comment signature does not match")
+	}
+	return comment, nil
+}
+
+// releaseSumFor returns the checksum SHA256SUMS lists for name, matching on
+// the base name so "./dist/x.tar.gz" and "*x.tar.gz" entries work.
+func releaseSumFor(sums []byte, name string) (string, error) {
+	name = filepath.Base(name)
+	for _, line := range strings.Split(string(sums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if filepath.Base(strings.TrimPrefix(fields[1], "*")) == name {
+			if _, err := hex.DecodeString(fields[0]); err != nil || len(fields[0]) != sha256.Size*2 {
+				return "", fmt.Errorf("%s has a malformed entry for %s", releaseSumsName, name)
+			}
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no entry for %s", releaseSumsName, name)
+}
+
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// minisignKeyID formats a key ID the way minisign prints it.
+func minisignKeyID(id []byte) string {
+	return fmt.Sprintf("%016X", binary.LittleEndian.Uint64(id))
+}
@@ -165,12 +165,16 @@ func fetchHerringboneReleases(endpoint string, limit int, includeDrafts bool, ti
 }
 
 func fetchHerringboneReleaseByTag(tag string, timeout time.Duration) (githubRelease, error) {
+	return fetchGitHubReleaseByTag(defaultReleaseTagURL, tag, timeout)
+}
+
+func fetchGitHubReleaseByTag(tagURL string, tag string, timeout time.Duration) (githubRelease, error) {
 	tag = strings.TrimSpace(tag)
 	if tag == "" {
 		return githubRelease{}, fmt.Errorf("release tag required")
 	}
 
-	endpoint := defaultReleaseTagURL + url.PathEscape(tag)
+	endpoint := tagURL + url.PathEscape(tag)
 	body, err := githubGet(endpoint, timeout)
 	if err != nil {
 		return githubRelease{}, err
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/herringbonedev/hbctl/internal/local"
 	"github.com/spf13/cobra"
@@ -13,6 +14,10 @@ func restartCommand() *cobra.Command {
 	var unit string
 	var all bool
 	var enterprise bool
+	var rolling bool
+	var readyTimeout time.Duration
+	var wait bool
+	var waitTimeout time.Duration
 
 	cmd := &cobra.Command{
 		Use:   "restart",
@@ -21,13 +26,18 @@ func restartCommand() *cobra.Command {
 			if !all && strings.TrimSpace(element) == "" && strings.TrimSpace(unit) == "" {
 				return fmt.Errorf("specify --element, --unit, or --all. Full-stack restart is no longer implicit")
 			}
-			return local.Restart(local.RestartOptions{
-				Project:    projectName,
-				Element:    strings.TrimSpace(element),
-				Unit:       strings.TrimSpace(unit),
-				All:        all,
-				Enterprise: enterprise,
-			})
+			if err := local.Restart(local.RestartOptions{
+				Project:      projectName,
+				Element:      strings.TrimSpace(element),
+				Unit:         strings.TrimSpace(unit),
+				All:          all,
+				Enterprise:   enterprise,
+				Rolling:      rolling,
+				ReadyTimeout: readyTimeout,
+			}); err != nil {
+				return err
+			}
+			return waitAfterLifecycle(cmd, wait, element, unit, all, enterprise, waitTimeout)
 		},
 	}
 
@@ -35,5 +45,9 @@ func restartCommand() *cobra.Command {
 	cmd.Flags().StringVar(&unit, "unit", "", "Unit to restart")
 	cmd.Flags().BoolVar(&all, "all", false, "Restart the full stack")
 	cmd.Flags().BoolVar(&enterprise, "enterprise", false, "Restart enterprise services and set HB_ENTERPRISE=true")
+	cmd.Flags().BoolVar(&rolling, "rolling", false, "Restart multi-replica elements one container at a time, waiting for each to be healthy")
+	cmd.Flags().DurationVar(&readyTimeout, "ready-timeout", local.DefaultReadyTimeout, "How long to wait for each element's readiness probe; 0 disables the wait")
+	cmd.Flags().BoolVar(&wait, "wait", false, "After the command finishes, block until the target is ready (like hbctl wait)")
+	cmd.Flags().DurationVar(&waitTimeout, "wait-timeout", local.DefaultWaitTimeout, "Timeout for --wait")
 	return cmd
 }
@@ -5,18 +5,21 @@ import (
 
 	"github.com/herringbonedev/hbctl/internal/secrets"
 	"github.com/herringbonedev/hbctl/internal/ui"
+	"github.com/herringbonedev/hbctl/internal/units"
 	"github.com/spf13/cobra"
 )
 
 var (
 	projectName        = "herringbone"
 	secretsDirOverride = ""
+	catalogPath        = ""
 	rootCmd            = &cobra.Command{
-		Use:           "hbctl",
-		Short:         "Control and manage a Herringbone deployment",
-		Long:          "hbctl manages Herringbone services, units, receivers, secrets, and local Docker Compose workflows.",
-		SilenceUsage:  true,
-		SilenceErrors: true,
+		Use:               "hbctl",
+		Short:             "Control and manage a Herringbone deployment",
+		Long:              "hbctl manages Herringbone services, units, receivers, secrets, and local Docker Compose workflows.",
+		SilenceUsage:      true,
+		SilenceErrors:     true,
+		PersistentPreRunE: loadElementCatalog,
 	}
 )
 
@@ -27,6 +30,45 @@ func Execute() {
 	}
 }
 
+// skipCatalogAnnotation marks commands that never look at units or elements,
+// so a broken catalog manifest cannot stop them from running.
+const skipCatalogAnnotation = "hbctl.skip-catalog"
+
+// loadElementCatalog replaces the built-in unit/element tables with the
+// Herringbone checkout's catalog manifest when one is present. A manifest
+// named with --catalog or HBCTL_CATALOG must load; one that was only found in
+// the working directory falls back to the built-in tables with a warning.
+func loadElementCatalog(cmd *cobra.Command, args []string) error {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.Annotations[skipCatalogAnnotation] != "" {
+			return nil
+		}
+	}
+	path, explicit, err := units.FindCatalog(catalogPath)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return nil
+	}
+	if err := units.LoadCatalog(path); err != nil {
+		if explicit {
+			return err
+		}
+		ui.FWarn(os.Stderr, "Using the built-in catalog; %s could not be loaded: %v", path, err)
+	}
+	return nil
+}
+
+// withoutCatalog marks cmd and its subcommands as not needing the catalog.
+func withoutCatalog(cmd *cobra.Command) *cobra.Command {
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	cmd.Annotations[skipCatalogAnnotation] = "true"
+	return cmd
+}
+
 func init() {
 	cobra.OnInitialize(func() {
 		secrets.SetBaseDir(secretsDirOverride)
@@ -36,25 +78,35 @@ func init() {
 	rootCmd.SetErr(os.Stderr)
 	rootCmd.PersistentFlags().StringVar(&projectName, "project", "herringbone", "Compose project name")
 	rootCmd.PersistentFlags().StringVar(&secretsDirOverride, "secrets", "", "Use an alternate hbctl secrets directory instead of the default")
+	rootCmd.PersistentFlags().StringVar(&catalogPath, "catalog", "", "Element catalog manifest; defaults to HBCTL_CATALOG, ./"+units.CatalogFileName+", or ./docker/"+units.CatalogFileName)
 
-	rootCmd.AddCommand(versionCommand())
+	rootCmd.AddCommand(withoutCatalog(versionCommand()))
 	rootCmd.AddCommand(elementsCommand())
 	rootCmd.AddCommand(unitsCommand())
+	rootCmd.AddCommand(graphCommand())
 	rootCmd.AddCommand(startCommand())
 	rootCmd.AddCommand(stopCommand())
 	rootCmd.AddCommand(restartCommand())
 	rootCmd.AddCommand(upgradeCommand())
+	rootCmd.AddCommand(scaleCommand())
 	rootCmd.AddCommand(statusCommand())
+	rootCmd.AddCommand(diffCommand())
+	rootCmd.AddCommand(waitCommand())
+	rootCmd.AddCommand(applyCommand())
 	rootCmd.AddCommand(pruneCommand())
+	rootCmd.AddCommand(imagesCommand())
+	rootCmd.AddCommand(lockCommand())
 	rootCmd.AddCommand(logsCommand())
-	rootCmd.AddCommand(loginCommand())
+	rootCmd.AddCommand(withoutCatalog(loginCommand()))
 	rootCmd.AddCommand(mongodbCommand())
-	rootCmd.AddCommand(logoutCommand())
-	rootCmd.AddCommand(serverCommand())
-	rootCmd.AddCommand(contextCommand())
-	rootCmd.AddCommand(bootstrapCommand())
-	rootCmd.AddCommand(whoamiCommand())
+	rootCmd.AddCommand(withoutCatalog(logoutCommand()))
+	rootCmd.AddCommand(withoutCatalog(serverCommand()))
+	rootCmd.AddCommand(withoutCatalog(contextCommand()))
+	rootCmd.AddCommand(withoutCatalog(bootstrapCommand()))
+	rootCmd.AddCommand(withoutCatalog(whoamiCommand()))
+	rootCmd.AddCommand(withoutCatalog(secretsCommand()))
 	rootCmd.AddCommand(receiverCommand())
-	rootCmd.AddCommand(releasesCommand())
+	rootCmd.AddCommand(withoutCatalog(releasesCommand()))
+	rootCmd.AddCommand(withoutCatalog(selfUpdateCommand()))
 	rootCmd.AddCommand(modelCommand())
 }
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/herringbonedev/hbctl/internal/local"
+	"github.com/spf13/cobra"
+)
+
+func scaleCommand() *cobra.Command {
+	var enterprise bool
+	var readyTimeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "scale [element=replicas|default ...]",
+		Short: "Set and persist replica counts, or list them when no counts are given",
+		Long: "Set replica counts, including 1, that later start, restart, upgrade, and apply runs keep.\n" +
+			"element=default removes the stored count so the element goes back to its compose default.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			replicas := map[string]int{}
+			reset := []string{}
+			seen := map[string]bool{}
+			for _, arg := range args {
+				name, value, ok := strings.Cut(arg, "=")
+				name = strings.TrimSpace(name)
+				if !ok || name == "" {
+					return fmt.Errorf("invalid scale target %q: expected element=replicas", arg)
+				}
+				if seen[name] {
+					return fmt.Errorf("%s is listed more than once", name)
+				}
+				seen[name] = true
+				if strings.TrimSpace(value) == "default" {
+					reset = append(reset, name)
+					continue
+				}
+				count, err := strconv.Atoi(strings.TrimSpace(value))
+				if err != nil {
+					return fmt.Errorf("invalid replica count in %q: %w", arg, err)
+				}
+				replicas[name] = count
+			}
+			return local.Scale(local.ScaleOptions{
+				Project:      projectName,
+				Replicas:     replicas,
+				Reset:        reset,
+				Enterprise:   enterprise,
+				ReadyTimeout: readyTimeout,
+			})
+		},
+	}
+
+	cmd.Flags().BoolVar(&enterprise, "enterprise", false, "Allow scaling enterprise services")
+	cmd.Flags().DurationVar(&readyTimeout, "ready-timeout", local.DefaultReadyTimeout, "How long to wait for each element's readiness probe; 0 disables the wait")
+	return cmd
+}
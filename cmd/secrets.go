@@ -0,0 +1,419 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/herringbonedev/hbctl/internal/local"
+	"github.com/herringbonedev/hbctl/internal/secrets"
+	"github.com/herringbonedev/hbctl/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func secretsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Manage the encrypted hbctl secrets store",
+	}
+	cmd.AddCommand(secretsListCommand())
+	cmd.AddCommand(secretsInfoCommand())
+	cmd.AddCommand(secretsRekeyCommand())
+	cmd.AddCommand(secretsExportCommand())
+	cmd.AddCommand(secretsImportCommand())
+	cmd.AddCommand(secretsIdentityCommand())
+	cmd.AddCommand(secretsRecipientsCommand())
+	return cmd
+}
+
+func secretsListCommand() *cobra.Command {
+	var jsonOut bool
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Show which secrets are stored, with metadata but no secret values",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := secrets.Inventory()
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if jsonOut {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(entries)
+			}
+
+			ui.FHeader(out, "hbctl secrets")
+			rows := make([][]string, 0, len(entries))
+			missing := 0
+			for _, entry := range entries {
+				if !entry.Present {
+					missing++
+					rows = append(rows, []string{entry.Name, ui.Yellow("missing"), ui.Dim(entry.Fill), "-"})
+					continue
+				}
+				details := make([]string, 0, len(entry.Details))
+				for _, detail := range entry.Details {
+					details = append(details, detail.Key+"="+blankDash(detail.Value))
+				}
+				rows = append(rows, []string{entry.Name, ui.Green("present"), strings.Join(details, " "), blankDash(entry.SavedAt)})
+			}
+			ui.FTable(out, []string{"ENTRY", "STATE", "DETAILS", "SAVED"}, rows)
+			if missing > 0 {
+				ui.FInfo(out, "%d of %d entries missing; run the command shown for each to store it", missing, len(entries))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "Print the inventory as JSON")
+	return cmd
+}
+
+func secretsInfoCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "info",
+		Short: "Show the file format and key derivation of secrets.enc without decrypting it",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			ui.FHeader(out, "hbctl secrets info")
+			info, err := secrets.Info()
+			if err != nil {
+				return err
+			}
+			format := fmt.Sprintf("v%d", info.Version)
+			if info.Version == 0 {
+				format = "legacy (no header)"
+			}
+			ui.FKeyValues(out, [][2]string{
+				{"path", info.Path},
+				{"format", format},
+				{"kdf", info.KDF},
+				{"parameters", info.KDFParams},
+				{"size", fmt.Sprintf("%d bytes", info.Size)},
+				{"modified", info.Modified.Format(time.RFC3339)},
+			})
+			if len(info.Recipients) > 0 {
+				ui.FSuccess(out, "secrets.enc is encrypted to %d recipient(s); see hbctl secrets recipients list", len(info.Recipients))
+			} else if info.Current {
+				ui.FSuccess(out, "secrets.enc uses the current format")
+			} else {
+				ui.FInfo(out, "The next save rewrites secrets.enc in the current format with argon2id; hbctl secrets rekey does it now.")
+			}
+			return nil
+		},
+	}
+}
+
+func secretsRekeyCommand() *cobra.Command {
+	var confirm bool
+
+	cmd := &cobra.Command{
+		Use:   "rekey",
+		Short: "Re-encrypt secrets.enc under a new passphrase",
+		Long: "Decrypt secrets.enc with the current passphrase (HBCTL_PASSPHRASE or a prompt) and re-encrypt it with a new one\n" +
+			"(HBCTL_NEW_PASSPHRASE or a confirmed prompt). The previous file is kept as secrets.enc.<timestamp>.bak;\n" +
+			"once hbctl works with the new passphrase, remove it with hbctl secrets rekey --confirm.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			if confirm {
+				ui.FHeader(out, "hbctl secrets rekey confirm")
+				removed, err := secrets.ConfirmRekey()
+				if err != nil {
+					return err
+				}
+				if len(removed) == 0 {
+					ui.FSkip(out, "No rekey backups to remove")
+					return nil
+				}
+				for _, backup := range removed {
+					ui.FSuccess(out, "Removed %s", backup)
+				}
+				return nil
+			}
+
+			ui.FHeader(out, "hbctl secrets rekey")
+			backup, err := secrets.Rekey()
+			if err != nil {
+				return err
+			}
+			ui.FSuccess(out, "secrets.enc re-encrypted with the new passphrase")
+			ui.FKeyValues(out, [][2]string{{"backup", backup}})
+			ui.FInfo(out, "The backup still opens with the old passphrase. Remove it with hbctl secrets rekey --confirm once hbctl works with the new one.")
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&confirm, "confirm", false, "Check that secrets.enc opens with the current passphrase, then remove the rekey backups")
+	return cmd
+}
+
+func secretsExportCommand() *cobra.Command {
+	var output string
+	var runtime bool
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write the secrets store to a bundle encrypted with a separate export passphrase",
+		Long: "Write every secrets.enc entry, and with --runtime the runtime token files, to a bundle encrypted with an export\n" +
+			"passphrase taken from HBCTL_EXPORT_PASSPHRASE or a confirmed prompt. Restore it with hbctl secrets import.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := secrets.ExportOptions{Output: strings.TrimSpace(output), Force: force}
+			if runtime {
+				dir, err := local.RuntimeSecretsDir(secretsDirOverride)
+				if err != nil {
+					return err
+				}
+				opts.RuntimeDir = dir
+			}
+
+			out := cmd.OutOrStdout()
+			ui.FHeader(out, "hbctl secrets export")
+			summary, err := secrets.Export(opts)
+			if err != nil {
+				return err
+			}
+			rows := [][2]string{{"bundle", summary.Path}, {"entries", blankDash(strings.Join(summary.Entries, ", "))}}
+			if runtime {
+				rows = append(rows, [2]string{"runtime", opts.RuntimeDir}, [2]string{"runtime files", blankDash(strings.Join(summary.Runtime, ", "))})
+			}
+			ui.FKeyValues(out, rows)
+			ui.FSuccess(out, "Exported %d entry(s)", len(summary.Entries))
+			ui.FInfo(out, "Keep the bundle and its export passphrase apart; anyone with both has every stored credential.")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "hbctl-secrets.hbsec", "Bundle file to write")
+	cmd.Flags().BoolVar(&runtime, "runtime", false, "Include the runtime token files written by hbctl start")
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite an existing bundle file")
+	return cmd
+}
+
+func secretsImportCommand() *cobra.Command {
+	var entries []string
+	var policy string
+	var runtime bool
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "import <bundle>",
+		Short: "Restore entries from a bundle written by hbctl secrets export",
+		Long: "Decrypt a bundle with its export passphrase (HBCTL_EXPORT_PASSPHRASE or a prompt) and restore its entries into\n" +
+			"secrets.enc. Every entry is compared first: with --policy merge, entries that differ locally are kept; with\n" +
+			"--policy replace, the bundle wins. Conflicts are reported before anything is written.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := secrets.ImportOptions{Path: strings.TrimSpace(args[0]), Entries: entries, Policy: policy}
+			if runtime {
+				dir, err := local.RuntimeSecretsDir(secretsDirOverride)
+				if err != nil {
+					return err
+				}
+				opts.RuntimeDir = dir
+			}
+
+			out := cmd.OutOrStdout()
+			ui.FHeader(out, "hbctl secrets import")
+			plan, err := secrets.PlanImport(opts)
+			if err != nil {
+				return err
+			}
+			ui.FKeyValues(out, [][2]string{{"bundle", opts.Path}, {"exported", blankDash(plan.Created)}, {"policy", plan.Policy}})
+			if len(plan.Items) == 0 {
+				ui.FSkip(out, "The bundle has nothing to import")
+				return nil
+			}
+
+			rows := make([][]string, 0, len(plan.Items))
+			writes, conflicts := 0, 0
+			for _, item := range plan.Items {
+				action := item.Action
+				result := "keep local"
+				switch {
+				case item.Action == secrets.ImportAdd:
+					action = ui.Green(action)
+					result = "import"
+				case item.Action == secrets.ImportConflict:
+					conflicts++
+					action = ui.Yellow(action)
+					if item.Write {
+						result = ui.Yellow("replace local")
+					}
+				default:
+					result = "-"
+				}
+				if item.Write {
+					writes++
+				}
+				rows = append(rows, []string{item.Name, action, result})
+			}
+			ui.FTable(out, []string{"ENTRY", "STATE", "RESULT"}, rows)
+			if conflicts > 0 && plan.Policy == secrets.ImportMerge {
+				ui.FWarn(out, "%d conflicting item(s) kept as stored locally; rerun with --policy replace to take the bundle's values", conflicts)
+			}
+			if dryRun {
+				ui.FInfo(out, "Dry run: %d item(s) would be written", writes)
+				return nil
+			}
+			if writes == 0 {
+				ui.FSuccess(out, "Nothing to write")
+				return nil
+			}
+
+			backup, err := secrets.ApplyImport(plan)
+			if err != nil {
+				return err
+			}
+			if backup != "" {
+				ui.FKeyValues(out, [][2]string{{"backup", backup}})
+			}
+			ui.FSuccess(out, "Imported %d item(s)", writes)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&entries, "entry", nil, "Only import these entries ("+strings.Join(secrets.StoreEntryNames(), ", ")+"); repeatable")
+	cmd.Flags().StringVar(&policy, "policy", secrets.ImportMerge, "How to handle entries already stored with other values: merge keeps them, replace overwrites them")
+	cmd.Flags().BoolVar(&runtime, "runtime", false, "Also restore the bundle's runtime token files")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report what would be imported without writing anything")
+	return cmd
+}
+
+func secretsIdentityCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "identity",
+		Short: "Show your X25519 public key, creating the identity file if needed",
+		Long: "Print the public key of your identity file (HBCTL_IDENTITY or ~/.hbctl/identity), creating it on first use.\n" +
+			"Send the public key to a teammate who already has access so they can run hbctl secrets recipients add.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			ui.FHeader(out, "hbctl secrets identity")
+			public, path, created, err := secrets.EnsureIdentity()
+			if err != nil {
+				return err
+			}
+			if created {
+				ui.FSuccess(out, "Created identity %s", path)
+			}
+			ui.FKeyValues(out, [][2]string{{"identity", path}, {"public key", public}})
+			ui.FInfo(out, "The identity file is your private key; keep it out of the repository.")
+			return nil
+		},
+	}
+}
+
+func secretsRecipientsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "recipients",
+		Short: "Encrypt secrets.enc to teammates' X25519 public keys instead of a passphrase",
+		Long: "In recipient mode secrets.enc is encrypted to a list of X25519 public keys and each engineer opens it with\n" +
+			"their own identity file. Adding or removing a recipient re-wraps the store without re-entering any secret;\n" +
+			"the previous file is kept as secrets.enc.<timestamp>.bak until hbctl secrets rekey --confirm removes it.",
+	}
+	cmd.AddCommand(secretsRecipientsListCommand())
+	cmd.AddCommand(secretsRecipientsAddCommand())
+	cmd.AddCommand(secretsRecipientsRemoveCommand())
+	cmd.AddCommand(secretsRecipientsDisableCommand())
+	return cmd
+}
+
+func secretsRecipientsListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the public keys secrets.enc is encrypted to",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			ui.FHeader(out, "hbctl secrets recipients")
+			recipients, ok, err := secrets.ListRecipients()
+			if err != nil {
+				return err
+			}
+			if !ok {
+				ui.FSkip(out, "secrets.enc is protected by a passphrase; add a recipient to switch to recipient mode")
+				return nil
+			}
+			mine := secrets.IdentityPublicKey()
+			rows := make([][]string, 0, len(recipients))
+			for _, recipient := range recipients {
+				you := ""
+				if recipient.PublicKey == mine {
+					you = ui.Green("you")
+				}
+				rows = append(rows, []string{blankDash(recipient.Label), recipient.PublicKey, you})
+			}
+			ui.FTable(out, []string{"NAME", "PUBLIC KEY", ""}, rows)
+			return nil
+		},
+	}
+}
+
+func secretsRecipientsAddCommand() *cobra.Command {
+	var name string
+
+	cmd := &cobra.Command{
+		Use:   "add <public-key>",
+		Short: "Encrypt secrets.enc to another public key",
+		Long: "Add a recipient. A passphrase-protected store is switched to recipient mode and encrypted to your identity\n" +
+			"(created if needed) and the new key; the passphrase is asked for once to open it.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			ui.FHeader(out, "hbctl secrets recipients add")
+			backup, err := secrets.AddRecipient(args[0], name)
+			if err != nil {
+				return err
+			}
+			ui.FSuccess(out, "secrets.enc is now encrypted to %s", args[0])
+			ui.FKeyValues(out, [][2]string{{"backup", backup}})
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "Label shown for the recipient, such as an email address")
+	return cmd
+}
+
+func secretsRecipientsRemoveCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <public-key|name>",
+		Short: "Stop encrypting secrets.enc to a public key",
+		Long: "Remove a recipient and re-encrypt secrets.enc under a new data key. The removed key can still open backups\n" +
+			"and any copy it already has, so rotate the stored credentials if access is being revoked.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			ui.FHeader(out, "hbctl secrets recipients remove")
+			backup, err := secrets.RemoveRecipient(args[0])
+			if err != nil {
+				return err
+			}
+			ui.FSuccess(out, "Removed recipient %s", args[0])
+			ui.FKeyValues(out, [][2]string{{"backup", backup}})
+			ui.FInfo(out, "The backup still opens with the removed key; delete it with hbctl secrets rekey --confirm.")
+			return nil
+		},
+	}
+}
+
+func secretsRecipientsDisableCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "disable",
+		Short: "Switch secrets.enc back to a passphrase",
+		Long:  "Re-encrypt secrets.enc with a passphrase from HBCTL_PASSPHRASE or a confirmed prompt, dropping every recipient.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			ui.FHeader(out, "hbctl secrets recipients disable")
+			backup, err := secrets.DisableRecipients()
+			if err != nil {
+				return err
+			}
+			ui.FSuccess(out, "secrets.enc is protected by a passphrase again")
+			ui.FKeyValues(out, [][2]string{{"backup", backup}})
+			return nil
+		},
+	}
+}
@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/herringbonedev/hbctl/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+const hbctlReleasesURL = "https://api.github.com/repos/herringbonedev/hbctl/releases"
+const hbctlReleaseTagURL = "https://api.github.com/repos/herringbonedev/hbctl/releases/tags/"
+
+// hbctlChecksumAssets are the checksum files hbctl releases may publish; the
+// release workflow writes checksums.txt.
+var hbctlChecksumAssets = []string{"checksums.txt", releaseSumsName}
+
+func selfUpdateCommand() *cobra.Command {
+	var version string
+	var check bool
+	var rollback bool
+	var timeoutSeconds int
+
+	cmd := &cobra.Command{
+		Use:   "self-update",
+		Short: "Update hbctl from its GitHub releases, or roll back to the previous binary",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if timeoutSeconds <= 0 {
+				return fmt.Errorf("--timeout must be greater than zero")
+			}
+			if rollback && (check || strings.TrimSpace(version) != "") {
+				return fmt.Errorf("--rollback cannot be combined with --check or --version")
+			}
+			exe, err := hbctlExecutable()
+			if err != nil {
+				return err
+			}
+			if rollback {
+				return rollbackSelfUpdate(exe)
+			}
+			return selfUpdate(exe, strings.TrimSpace(version), check, time.Duration(timeoutSeconds)*time.Second)
+		},
+	}
+
+	cmd.Flags().StringVar(&version, "version", "", "Release tag to install instead of the newest release")
+	cmd.Flags().BoolVar(&check, "check", false, "Only report whether a newer hbctl is available")
+	cmd.Flags().BoolVar(&rollback, "rollback", false, "Restore the binary kept as <hbctl>.bak by the last self-update")
+	cmd.Flags().IntVar(&timeoutSeconds, "timeout", 30, "GitHub API/download timeout in seconds")
+	return cmd
+}
+
+func selfUpdate(exe string, version string, check bool, timeout time.Duration) error {
+	ui.Header("hbctl self-update")
+
+	var release githubRelease
+	if version != "" {
+		found, err := fetchGitHubReleaseByTag(hbctlReleaseTagURL, version, timeout)
+		if err != nil {
+			return err
+		}
+		release = found
+	} else {
+		releases, err := fetchHerringboneReleases(hbctlReleasesURL, 10, false, timeout)
+		if err != nil {
+			return err
+		}
+		if len(releases) == 0 {
+			return fmt.Errorf("no hbctl releases found")
+		}
+		release = releases[0]
+	}
+
+	asset := selectHbctlBinaryAsset(release)
+	ui.KeyValues([][2]string{
+		{"current", Version},
+		{"release", release.TagName},
+		{"platform", runtime.GOOS + "/" + runtime.GOARCH},
+		{"asset", blankDash(asset.Name)},
+		{"binary", exe},
+	})
+	if asset.Name == "" {
+		return fmt.Errorf("release %s has no hbctl binary for %s/%s", release.TagName, runtime.GOOS, runtime.GOARCH)
+	}
+
+	current := release.TagName == Version
+	if running, ok := parseHbctlVersion(Version); ok {
+		if latest, ok := parseHbctlVersion(release.TagName); ok && version == "" {
+			current = compareHbctlVersions(running, latest) >= 0
+		}
+	}
+	if check {
+		if current {
+			ui.Success("hbctl %s is up to date", Version)
+		} else {
+			ui.Info("hbctl %s is available; run hbctl self-update to install it", release.TagName)
+		}
+		return nil
+	}
+	if current {
+		ui.Success("hbctl %s is already installed", Version)
+		return nil
+	}
+
+	dir := filepath.Dir(exe)
+	tmp, err := os.CreateTemp(dir, ".hbctl-update-*")
+	if err != nil {
+		return fmt.Errorf("cannot write to %s: %w; rerun with permission to replace %s", dir, err, exe)
+	}
+	tmpPath := tmp.Name()
+	_ = tmp.Close()
+	defer os.Remove(tmpPath)
+
+	ui.Section("Download hbctl")
+	if err := downloadReleaseArchive(asset.BrowserDownloadURL, tmpPath, timeout); err != nil {
+		return err
+	}
+	ui.Success("Downloaded %s", asset.Name)
+
+	ui.Section("Verify hbctl")
+	digest, err := fileSHA256(tmpPath)
+	if err != nil {
+		return err
+	}
+	sums := githubReleaseAsset{}
+	for _, name := range hbctlChecksumAssets {
+		for _, candidate := range release.Assets {
+			if sums.Name == "" && candidate.Name == name {
+				sums = candidate
+			}
+		}
+	}
+	if sums.Name == "" {
+		return fmt.Errorf("release %s publishes no checksum file; refusing to install an unverified binary", release.TagName)
+	}
+	sumsPath := tmpPath + ".sums"
+	defer os.Remove(sumsPath)
+	if err := downloadReleaseArchive(sums.BrowserDownloadURL, sumsPath, timeout); err != nil {
+		return err
+	}
+	sumsData, err := os.ReadFile(sumsPath)
+	if err != nil {
+		return err
+	}
+	want, err := releaseSumFor(sumsData, asset.Name)
+	if err != nil {
+		return err
+	}
+	ui.KeyValues([][2]string{{"sha256", digest}, {"checksums", sums.Name}})
+	if want != digest {
+		return fmt.Errorf("%s checksum mismatch: %s lists %s, the download is %s", asset.Name, sums.Name, want, digest)
+	}
+	ui.Success("Checksum matches")
+
+	if err := os.Chmod(tmpPath, 0o755); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if out, err := exec.CommandContext(ctx, tmpPath, "version").CombinedOutput(); err != nil {
+		return fmt.Errorf("downloaded hbctl does not run on this host: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	ui.Section("Install hbctl")
+	if err := replaceExecutable(exe, tmpPath); err != nil {
+		return err
+	}
+	ui.Success("hbctl %s installed to %s", release.TagName, exe)
+	ui.Info("The previous binary was kept as %s; hbctl self-update --rollback restores it.", exe+".bak")
+	return nil
+}
+
+// rollbackSelfUpdate swaps the running binary with <exe>.bak, so a second
+// rollback returns to the updated binary.
+func rollbackSelfUpdate(exe string) error {
+	ui.Header("hbctl self-update rollback")
+	backup := exe + ".bak"
+	if _, err := os.Stat(backup); err != nil {
+		return fmt.Errorf("no previous binary at %s", backup)
+	}
+	ui.KeyValues([][2]string{{"current", Version}, {"binary", exe}, {"backup", backup}})
+
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".hbctl-rollback-*")
+	if err != nil {
+		return fmt.Errorf("cannot write to %s: %w", filepath.Dir(exe), err)
+	}
+	tmpPath := tmp.Name()
+	_ = tmp.Close()
+	defer os.Remove(tmpPath)
+	if err := copyExecutable(backup, tmpPath); err != nil {
+		return err
+	}
+	if err := replaceExecutable(exe, tmpPath); err != nil {
+		return err
+	}
+	ui.Success("Restored the previous hbctl to %s", exe)
+	return nil
+}
+
+// replaceExecutable keeps a copy of exe as exe.bak and moves replacement into
+// place with a rename, so exe is never missing or half written. Windows cannot
+// rename over a running binary, so there the old one is moved aside first.
+func replaceExecutable(exe string, replacement string) error {
+	backup := exe + ".bak"
+	if runtime.GOOS == "windows" {
+		_ = os.Remove(backup)
+		if err := os.Rename(exe, backup); err != nil {
+			return err
+		}
+		if err := os.Rename(replacement, exe); err != nil {
+			_ = os.Rename(backup, exe)
+			return err
+		}
+		return nil
+	}
+
+	backupTmp := backup + ".tmp"
+	if err := copyExecutable(exe, backupTmp); err != nil {
+		return err
+	}
+	if err := os.Rename(backupTmp, backup); err != nil {
+		_ = os.Remove(backupTmp)
+		return err
+	}
+	return os.Rename(replacement, exe)
+}
+
+func copyExecutable(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chmod(dst, 0o755)
+}
+
+// hbctlExecutable returns the real path of the running binary, following
+// symlinks so the file itself is replaced rather than the link.
+func hbctlExecutable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(exe)
+}
+
+// selectHbctlBinaryAsset picks the release binary for this platform. The
+// release workflow names them hbctl_<version>_<os>_<arch>, with .exe on
+// Windows.
+func selectHbctlBinaryAsset(release githubRelease) githubReleaseAsset {
+	suffix := "_" + runtime.GOOS + "_" + runtime.GOARCH
+	if runtime.GOOS == "windows" {
+		suffix += ".exe"
+	}
+	for _, asset := range release.Assets {
+		name := strings.ToLower(strings.TrimSpace(asset.Name))
+		if strings.HasPrefix(name, "hbctl") && strings.HasSuffix(name, suffix) {
+			return asset
+		}
+	}
+	return githubReleaseAsset{}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/herringbonedev/hbctl/internal/local"
 	"github.com/spf13/cobra"
@@ -18,7 +19,13 @@ func startCommand() *cobra.Command {
 	var noTokenCreate bool
 	var bootstrapTokens bool
 	var enterprise bool
+	var readyTimeout time.Duration
 	var model string
+	var parallel int
+	var rollbackOnFailure bool
+	var rollbackCore bool
+	var wait bool
+	var waitTimeout time.Duration
 
 	cmd := &cobra.Command{
 		Use:   "start",
@@ -33,6 +40,10 @@ func startCommand() *cobra.Command {
 				return fmt.Errorf("specify --element, --unit, or --all")
 			}
 
+			if parallel < 1 {
+				return fmt.Errorf("--parallel must be at least 1")
+			}
+
 			if strings.TrimSpace(receiverType) != "" {
 				normalized, err := normalizeReceiverType(receiverType)
 				if err != nil {
@@ -41,18 +52,25 @@ func startCommand() *cobra.Command {
 				receiverType = strings.ToUpper(normalized)
 			}
 
-			return local.Start(local.StartOptions{
-				Project:         projectName,
-				SecretsDir:      secretsDirOverride,
-				Element:         strings.TrimSpace(element),
-				Unit:            strings.TrimSpace(unit),
-				All:             all,
-				RecvType:        receiverType,
-				TokenCreate:     tokenCreate,
-				NoTokenCreate:   noTokenCreate,
-				BootstrapTokens: bootstrapTokens,
-				Enterprise:      enterprise,
-			})
+			if err := local.Start(local.StartOptions{
+				Project:           projectName,
+				SecretsDir:        secretsDirOverride,
+				Element:           strings.TrimSpace(element),
+				Unit:              strings.TrimSpace(unit),
+				All:               all,
+				RecvType:          receiverType,
+				TokenCreate:       tokenCreate,
+				NoTokenCreate:     noTokenCreate,
+				BootstrapTokens:   bootstrapTokens,
+				Enterprise:        enterprise,
+				Parallel:          parallel,
+				ReadyTimeout:      readyTimeout,
+				RollbackOnFailure: rollbackOnFailure,
+				RollbackCore:      rollbackCore,
+			}); err != nil {
+				return err
+			}
+			return waitAfterLifecycle(cmd, wait, element, unit, all, enterprise, waitTimeout)
 		},
 	}
 
@@ -67,5 +85,11 @@ func startCommand() *cobra.Command {
 	_ = cmd.Flags().MarkHidden("no-token-create")
 	cmd.Flags().BoolVar(&enterprise, "enterprise", false, "Start enterprise services and set HB_ENTERPRISE=true")
 	cmd.Flags().StringVar(&model, "model", "", "Ollama model for enterprise fingerprint tuner")
+	cmd.Flags().IntVar(&parallel, "parallel", 1, "Start up to N independent elements at once")
+	cmd.Flags().DurationVar(&readyTimeout, "ready-timeout", local.DefaultReadyTimeout, "How long to wait for each element's readiness probe; 0 disables the wait")
+	cmd.Flags().BoolVar(&rollbackOnFailure, "rollback-on-failure", false, "If start --all fails, stop and remove the containers it created without prompting")
+	cmd.Flags().BoolVar(&rollbackCore, "rollback-core", false, "Let a rollback also remove MongoDB, proxy, and auth containers the failed start created; volumes are kept")
+	cmd.Flags().BoolVar(&wait, "wait", false, "After the command finishes, block until the target is ready (like hbctl wait)")
+	cmd.Flags().DurationVar(&waitTimeout, "wait-timeout", local.DefaultWaitTimeout, "Timeout for --wait")
 	return cmd
 }
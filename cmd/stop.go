@@ -17,6 +17,7 @@ func stopCommand() *cobra.Command {
 	var mongo bool
 	var auth bool
 	var keepContainers bool
+	var withDependents bool
 
 	cmd := &cobra.Command{
 		Use:   "stop",
@@ -35,6 +36,7 @@ func stopCommand() *cobra.Command {
 				Mongo:          mongo,
 				Auth:           auth,
 				KeepContainers: keepContainers,
+				WithDependents: withDependents,
 			})
 		},
 	}
@@ -47,5 +49,6 @@ func stopCommand() *cobra.Command {
 	cmd.Flags().BoolVar(&auth, "auth", false, "Explicitly stop the protected auth service")
 	cmd.Flags().BoolVar(&down, "down", false, "Deprecated for --all; hbctl now stops and prunes application containers without removing volumes")
 	cmd.Flags().BoolVar(&keepContainers, "keep-containers", false, "Stop containers but leave stopped containers present instead of pruning them")
+	cmd.Flags().BoolVar(&withDependents, "with-dependents", false, "With --element, also stop running application elements that depend on it, dependents first")
 	return cmd
 }
@@ -29,6 +29,7 @@ func unitsCommand() *cobra.Command {
 			}
 
 			ui.FHeader(cmd.OutOrStdout(), "Herringbone units")
+			ui.FKeyValues(cmd.OutOrStdout(), [][2]string{{"catalog", units.CatalogSource()}})
 			rows := make([][]string, 0, len(names))
 			for _, name := range names {
 				rows = append(rows, []string{name})
@@ -17,15 +17,25 @@ func upgradeCommand() *cobra.Command {
 	var noPull bool
 	var forceRecreate bool
 	var enterprise bool
+	var rolling bool
+	var safe bool
+	var readyTimeout time.Duration
+	var wait bool
+	var waitTimeout time.Duration
 	var dryRun bool
 	var listReleases bool
 	var releaseTag string
+	var releaseFile string
+	var releaseDir string
+	var rollback string
 	var applyNow bool
 	var limit int
 	var asJSON bool
 	var includeDrafts bool
 	var timeoutSeconds int
 	var assetName string
+	var insecureSkipVerify bool
+	var showDiff bool
 
 	cmd := &cobra.Command{
 		Use:   "upgrade",
@@ -52,23 +62,65 @@ func upgradeCommand() *cobra.Command {
 				return nil
 			}
 
-			if strings.TrimSpace(releaseTag) != "" {
-				if dryRun {
-					return fmt.Errorf("--dry-run is only supported for service refresh operations, not release staging")
+			sources := 0
+			for _, value := range []string{releaseTag, releaseFile, releaseDir, rollback} {
+				if strings.TrimSpace(value) != "" {
+					sources++
 				}
-				if err := stageReleaseFromGitHub(strings.TrimSpace(releaseTag), strings.TrimSpace(assetName), time.Duration(timeoutSeconds)*time.Second); err != nil {
+			}
+			if sources > 1 {
+				return fmt.Errorf("use only one of --release-tag, --release-file, --release-dir, or --rollback")
+			}
+			if sources == 1 && dryRun {
+				return fmt.Errorf("--dry-run is only supported for service refresh operations, not release staging or rollback")
+			}
+			if strings.TrimSpace(assetName) != "" && strings.TrimSpace(releaseTag) == "" {
+				return fmt.Errorf("--asset is only used with --release-tag")
+			}
+			if showDiff && (sources == 0 || strings.TrimSpace(rollback) != "") {
+				return fmt.Errorf("--diff is used with --release-tag, --release-file, or --release-dir")
+			}
+			if showDiff && applyNow {
+				return fmt.Errorf("--diff only previews a release; drop it to stage with --now")
+			}
+			stageOpts := releaseStageOptions{
+				AssetName:          strings.TrimSpace(assetName),
+				Timeout:            time.Duration(timeoutSeconds) * time.Second,
+				InsecureSkipVerify: insecureSkipVerify,
+				DiffOnly:           showDiff,
+			}
+
+			if sources == 1 {
+				var err error
+				switch {
+				case strings.TrimSpace(rollback) != "":
+					err = rollbackReleaseArchive(rollback)
+				case strings.TrimSpace(releaseFile) != "":
+					err = stageReleaseFromFile(strings.TrimSpace(releaseFile), stageOpts)
+				case strings.TrimSpace(releaseDir) != "":
+					err = stageReleaseFromDir(strings.TrimSpace(releaseDir), stageOpts)
+				default:
+					err = stageReleaseFromGitHub(strings.TrimSpace(releaseTag), stageOpts)
+				}
+				if err != nil {
 					return err
 				}
-				if !applyNow {
+				if !applyNow || showDiff {
 					return nil
 				}
 				all = true
 			}
 
 			if !all && strings.TrimSpace(element) == "" && strings.TrimSpace(unit) == "" {
-				return fmt.Errorf("specify --list-releases, --release-tag, --element, --unit, or --all")
+				return fmt.Errorf("specify --list-releases, --release-tag, --release-file, --release-dir, --rollback, --element, --unit, or --all")
+			}
+			if safe && strings.TrimSpace(element) == "" {
+				return fmt.Errorf("--safe upgrades one element at a time; use it with --element")
+			}
+			if safe && rolling {
+				return fmt.Errorf("use either --safe or --rolling, not both")
 			}
-			return local.Upgrade(local.UpgradeOptions{
+			if err := local.Upgrade(local.UpgradeOptions{
 				Project:       projectName,
 				Element:       strings.TrimSpace(element),
 				Unit:          strings.TrimSpace(unit),
@@ -77,14 +129,30 @@ func upgradeCommand() *cobra.Command {
 				ForceRecreate: forceRecreate,
 				Enterprise:    enterprise,
 				DryRun:        dryRun,
-			})
+				Rolling:       rolling,
+				Safe:          safe,
+				ReadyTimeout:  readyTimeout,
+			}); err != nil {
+				return err
+			}
+			if dryRun {
+				return nil
+			}
+			return waitAfterLifecycle(cmd, wait, element, unit, all, enterprise, waitTimeout)
 		},
 	}
 
+	cmd.AddCommand(upgradeArchivesCommand())
+
 	cmd.Flags().BoolVar(&listReleases, "list-releases", false, "List available GitHub releases")
 	cmd.Flags().StringVar(&releaseTag, "release-tag", "", "Download and stage a specific release tag")
-	cmd.Flags().BoolVar(&applyNow, "now", false, "After staging --release-tag, immediately run the safe upgrade refresh")
+	cmd.Flags().StringVar(&releaseFile, "release-file", "", "Stage a release archive (.tar.gz, .tgz, .tar, or .zip) from disk instead of GitHub")
+	cmd.Flags().StringVar(&releaseDir, "release-dir", "", "Stage an unpacked release payload directory from disk instead of GitHub")
+	cmd.Flags().BoolVar(&showDiff, "diff", false, "Show the files, images, and environment variables a release would change, without staging it")
+	cmd.Flags().StringVar(&rollback, "rollback", "", "Restore an archived compose directory by name, or \"previous\" for the newest archive")
+	cmd.Flags().BoolVar(&applyNow, "now", false, "After staging a release or restoring --rollback, immediately run the safe upgrade refresh")
 	cmd.Flags().StringVar(&assetName, "asset", "", "Specific release asset name to download instead of auto-selecting a tarball")
+	cmd.Flags().BoolVar(&insecureSkipVerify, "insecure-skip-verify", false, "Stage the release even if its SHA256SUMS checksum or signature cannot be verified")
 	cmd.Flags().IntVar(&limit, "limit", 25, "Maximum number of releases to list")
 	cmd.Flags().BoolVar(&asJSON, "json", false, "Output release list as JSON")
 	cmd.Flags().BoolVar(&includeDrafts, "include-drafts", false, "Include draft releases if the GitHub API token can see them")
@@ -97,5 +165,10 @@ func upgradeCommand() *cobra.Command {
 	cmd.Flags().BoolVar(&forceRecreate, "force-recreate", true, "Force container recreation during upgrade")
 	cmd.Flags().BoolVar(&enterprise, "enterprise", false, "Include enterprise services and set HB_ENTERPRISE=true")
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the upgrade plan and docker compose commands without running them")
+	cmd.Flags().BoolVar(&rolling, "rolling", false, "Replace multi-replica elements one container at a time, waiting for each to be healthy")
+	cmd.Flags().BoolVar(&safe, "safe", false, "Record the current image, start the new one next to it where ports allow, and roll back automatically if it fails its readiness probe")
+	cmd.Flags().DurationVar(&readyTimeout, "ready-timeout", local.DefaultReadyTimeout, "How long to wait for each element's readiness probe; 0 disables the wait")
+	cmd.Flags().BoolVar(&wait, "wait", false, "After the command finishes, block until the target is ready (like hbctl wait)")
+	cmd.Flags().DurationVar(&waitTimeout, "wait-timeout", local.DefaultWaitTimeout, "Timeout for --wait")
 	return cmd
 }
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/herringbonedev/hbctl/internal/local"
+	"github.com/spf13/cobra"
+)
+
+func waitCommand() *cobra.Command {
+	var element string
+	var unit string
+	var all bool
+	var enterprise bool
+	var timeout time.Duration
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "wait",
+		Short: "Block until an element, a unit, or the full stack is ready",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !all && strings.TrimSpace(element) == "" && strings.TrimSpace(unit) == "" {
+				return fmt.Errorf("specify --element, --unit, or --all")
+			}
+			if timeout <= 0 {
+				return fmt.Errorf("--timeout must be greater than zero")
+			}
+			return local.Wait(local.WaitOptions{
+				Project:    projectName,
+				Element:    strings.TrimSpace(element),
+				Unit:       strings.TrimSpace(unit),
+				All:        all,
+				Enterprise: enterprise,
+				Timeout:    timeout,
+				JSON:       asJSON,
+				Out:        cmd.OutOrStdout(),
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&element, "element", "", "Element to wait for")
+	cmd.Flags().StringVar(&unit, "unit", "", "Unit to wait for")
+	cmd.Flags().BoolVar(&all, "all", false, "Wait for the full stack")
+	cmd.Flags().BoolVar(&enterprise, "enterprise", false, "Include enterprise services")
+	cmd.Flags().DurationVar(&timeout, "timeout", local.DefaultWaitTimeout, "How long to wait before failing")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Output the readiness report as JSON")
+	return cmd
+}
+
+// waitAfterLifecycle runs the hbctl wait check for the target of a lifecycle
+// command when --wait is set.
+func waitAfterLifecycle(cmd *cobra.Command, wait bool, element, unit string, all, enterprise bool, timeout time.Duration) error {
+	if !wait {
+		return nil
+	}
+	return local.Wait(local.WaitOptions{
+		Project:    projectName,
+		Element:    strings.TrimSpace(element),
+		Unit:       strings.TrimSpace(unit),
+		All:        all,
+		Enterprise: enterprise,
+		Timeout:    timeout,
+		Out:        cmd.OutOrStdout(),
+	})
+}
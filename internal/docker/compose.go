@@ -1,6 +1,7 @@
 package docker
 
 import (
+	"io"
 	"os"
 	"os/exec"
 )
@@ -19,3 +20,19 @@ func ComposeWithEnv(env map[string]string, args ...string) error {
 	cmd.Stdin = os.Stdin
 	return cmd.Run()
 }
+
+// ComposeWithEnvTo runs docker compose with stdout and stderr sent to w and no
+// stdin, so output from concurrent runs can be buffered and printed per run.
+func ComposeWithEnvTo(w io.Writer, env map[string]string, args ...string) error {
+	full := append([]string{"compose"}, args...)
+	cmd := exec.Command("docker", full...)
+
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+
+	cmd.Stdout = w
+	cmd.Stderr = w
+	return cmd.Run()
+}
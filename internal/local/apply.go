@@ -0,0 +1,625 @@
+package local
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/herringbonedev/hbctl/internal/ui"
+	"github.com/herringbonedev/hbctl/internal/units"
+	"gopkg.in/yaml.v3"
+)
+
+// StackFileVersion is the newest stack file format this hbctl understands.
+const StackFileVersion = 1
+
+type ApplyOptions struct {
+	Project      string
+	SecretsDir   string
+	File         string
+	Prune        bool
+	DryRun       bool
+	ReadyTimeout time.Duration
+}
+
+type stackFile struct {
+	Version    int             `yaml:"version"`
+	Enterprise bool            `yaml:"enterprise"`
+	Units      []string        `yaml:"units"`
+	Elements   []string        `yaml:"elements"`
+	Replicas   map[string]int  `yaml:"replicas"`
+	Receivers  []stackReceiver `yaml:"receivers"`
+	Model      string          `yaml:"model"`
+}
+
+type stackReceiver struct {
+	Type             string `yaml:"type"`
+	Port             int    `yaml:"port"`
+	ContainerPort    int    `yaml:"container_port"`
+	Mode             string `yaml:"mode"`
+	ForwardRoute     string `yaml:"forward_route"`
+	IngestionKeyFile string `yaml:"ingestion_key_file"`
+}
+
+// applyStep is one row of the apply plan. Steps with action "ok" or "extra"
+// are reported but change nothing.
+type applyStep struct {
+	kind     string
+	name     string
+	action   string
+	detail   string
+	replicas int
+	receiver *stackReceiver
+	instance *ReceiverInstance
+}
+
+// Apply converges the local stack to a stack file: missing elements are
+// started through the normal start path, replica counts are scaled, receivers
+// are created or replaced, and the tuner model is saved. With Prune, running
+// elements and receivers the file does not list are removed. Protected core is
+// never started or removed here.
+func Apply(opts ApplyOptions) error {
+	stack, err := loadStackFile(opts.File)
+	if err != nil {
+		return err
+	}
+
+	ui.Header("Herringbone apply")
+	ui.KeyValues([][2]string{
+		{"stack file", opts.File},
+		{"project", opts.Project},
+		{"enterprise", fmt.Sprintf("%t", stack.Enterprise)},
+		{"prune", fmt.Sprintf("%t", opts.Prune)},
+	})
+
+	graph := buildElementGraph(blankLifecycleEnv(stack.Enterprise))
+	desired, err := stackElements(graph, stack)
+	if err != nil {
+		return err
+	}
+
+	steps, missingCore, err := planApply(opts, stack, desired)
+	if err != nil {
+		return err
+	}
+	printApplyPlan(steps)
+
+	if len(missingCore) > 0 {
+		return fmt.Errorf("protected core is not running (%s); run hbctl start --all before hbctl apply", strings.Join(missingCore, ", "))
+	}
+	changes := 0
+	for _, step := range steps {
+		if step.action != "ok" && step.action != "extra" {
+			changes++
+		}
+	}
+	if changes == 0 {
+		ui.Success("Stack is already in the desired state")
+		return nil
+	}
+	if opts.DryRun {
+		ui.Info("Dry run: %d change(s) planned, nothing applied", changes)
+		return nil
+	}
+
+	if err := convergeStack(opts, stack, graph, steps); err != nil {
+		return err
+	}
+	ui.Success("Applied %s (%d change(s))", opts.File, changes)
+	return nil
+}
+
+func loadStackFile(path string) (*stackFile, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("a stack file is required; pass -f stack.yaml")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var stack stackFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&stack); err != nil {
+		return nil, fmt.Errorf("invalid stack file %s: %w", path, err)
+	}
+	if stack.Version <= 0 {
+		return nil, fmt.Errorf("stack file %s is missing version", path)
+	}
+	if stack.Version > StackFileVersion {
+		return nil, fmt.Errorf("stack file %s uses version %d; this hbctl supports up to version %d", path, stack.Version, StackFileVersion)
+	}
+	stack.Model = strings.TrimSpace(stack.Model)
+
+	seen := map[string]bool{}
+	for i := range stack.Receivers {
+		receiver := &stack.Receivers[i]
+		receiver.Type = strings.ToLower(strings.TrimSpace(receiver.Type))
+		receiver.Mode = strings.ToLower(strings.TrimSpace(receiver.Mode))
+		receiver.ForwardRoute = strings.TrimSpace(receiver.ForwardRoute)
+		receiver.IngestionKeyFile = strings.TrimSpace(receiver.IngestionKeyFile)
+
+		switch receiver.Type {
+		case "http", "tcp", "udp", "remote":
+		default:
+			return nil, fmt.Errorf("stack file %s: invalid receiver type %q: expected http, tcp, udp, or remote", path, receiver.Type)
+		}
+		if receiver.Port <= 0 {
+			return nil, fmt.Errorf("stack file %s: %s receiver needs a port", path, receiver.Type)
+		}
+		if receiver.Mode == "" {
+			receiver.Mode = "local"
+			if receiver.ForwardRoute != "" {
+				receiver.Mode = "forward"
+			}
+		}
+		switch receiver.Mode {
+		case "local":
+			if receiver.ForwardRoute != "" {
+				return nil, fmt.Errorf("stack file %s: %s receiver on port %d sets forward_route in local mode", path, receiver.Type, receiver.Port)
+			}
+		case "forward":
+			if receiver.Type == "remote" {
+				return nil, fmt.Errorf("stack file %s: remote receivers cannot be forwarders", path)
+			}
+			if receiver.ForwardRoute == "" {
+				return nil, fmt.Errorf("stack file %s: %s receiver on port %d needs forward_route in forward mode", path, receiver.Type, receiver.Port)
+			}
+			if receiver.IngestionKeyFile == "" {
+				return nil, fmt.Errorf("stack file %s: %s receiver on port %d needs ingestion_key_file to forward", path, receiver.Type, receiver.Port)
+			}
+		default:
+			return nil, fmt.Errorf("stack file %s: invalid receiver mode %q: expected local or forward", path, receiver.Mode)
+		}
+		key := receiverStackKey(receiver.Type, receiver.Port)
+		if seen[key] {
+			return nil, fmt.Errorf("stack file %s declares the %s receiver on port %d more than once", path, receiver.Type, receiver.Port)
+		}
+		seen[key] = true
+	}
+	return &stack, nil
+}
+
+// stackElements expands the file's units and elements into the ordered set of
+// application elements it needs, dependencies included.
+func stackElements(graph *elementGraph, stack *stackFile) ([]string, error) {
+	targets := []string{}
+	for _, unit := range stack.Units {
+		unit = strings.TrimSpace(unit)
+		members := units.UnitElements[unit]
+		if len(members) == 0 {
+			return nil, fmt.Errorf("unknown unit in stack file: %s", unit)
+		}
+		for _, member := range members {
+			element := CanonicalElementName(member)
+			if isProtectedCoreService(element) || element == "logingestion-receiver" {
+				continue
+			}
+			if IsEnterpriseElement(element) && !stack.Enterprise {
+				ui.Skip("%s: enterprise service requires enterprise: true", element)
+				continue
+			}
+			targets = append(targets, element)
+		}
+	}
+	for _, name := range stack.Elements {
+		element := CanonicalElementName(name)
+		switch {
+		case !graphHasNode(graph, element):
+			return nil, fmt.Errorf("unknown element in stack file: %s", name)
+		case element == "logingestion-receiver":
+			return nil, fmt.Errorf("list logingestion-receiver under receivers, not elements")
+		case isProtectedCoreService(element):
+			return nil, fmt.Errorf("%s is protected core and is always required; remove it from elements", element)
+		case IsEnterpriseElement(element) && !stack.Enterprise:
+			return nil, fmt.Errorf("%s is an enterprise service; set enterprise: true to apply it", element)
+		}
+		targets = append(targets, element)
+	}
+
+	closure := graph.closure(uniqueStrings(targets), func(dep string) bool {
+		if isProtectedCoreService(dep) || dep == "logingestion-receiver" {
+			return false
+		}
+		return stack.Enterprise || !IsEnterpriseElement(dep)
+	})
+	ordered, err := graph.order(closure)
+	if err != nil {
+		return nil, err
+	}
+
+	inSet := map[string]bool{}
+	for _, element := range ordered {
+		inSet[element] = true
+	}
+	for name, count := range stack.Replicas {
+		element := CanonicalElementName(name)
+		if !inSet[element] {
+			return nil, fmt.Errorf("replicas set for %s, which the stack file does not start", name)
+		}
+		if count < 1 {
+			return nil, fmt.Errorf("replicas for %s must be at least 1", name)
+		}
+		if count > 1 && serviceHasFixedHostPort(element) {
+			return nil, fmt.Errorf("%s publishes a fixed host port and cannot run more than one replica", element)
+		}
+		if count > 1 {
+			port, err := elementFixedHostPort(blankLifecycleEnv(stack.Enterprise), element)
+			if err != nil {
+				return nil, err
+			}
+			if port != "" {
+				return nil, fmt.Errorf("%s publishes fixed host port %s and cannot run more than one replica", element, port)
+			}
+		}
+	}
+	return ordered, nil
+}
+
+// planApply compares the stack file with the running containers and
+// receivers. It also returns any protected core services that are not running.
+func planApply(opts ApplyOptions, stack *stackFile, desired []string) ([]applyStep, []string, error) {
+	containers, err := listHerringboneContainers(opts.Project, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	mainProject := strings.ToLower(strings.TrimSpace(opts.Project))
+	if mainProject == "" {
+		mainProject = "herringbone"
+	}
+	running := map[string]int{}
+	for _, container := range containers {
+		if container.Project != mainProject {
+			continue
+		}
+		running[CanonicalElementName(container.Service)]++
+	}
+
+	missingCore := []string{}
+	for _, core := range []string{"mongodb", "herringbone-proxy", AuthElementForMode(stack.Enterprise)} {
+		if running[core] == 0 {
+			missingCore = append(missingCore, core)
+		}
+	}
+
+	replicas := map[string]int{}
+	for name, count := range stack.Replicas {
+		replicas[CanonicalElementName(name)] = count
+	}
+
+	modelChanged := stack.Model != "" && stack.Model != ResolveFingerprintTunerModel()
+
+	steps := []applyStep{}
+	if stack.Model != "" {
+		step := applyStep{kind: "model", name: "fingerprint-tuner", action: "ok", detail: stack.Model}
+		if modelChanged {
+			step.action = "set"
+			step.detail = fmt.Sprintf("%s -> %s", ResolveFingerprintTunerModel(), stack.Model)
+		}
+		steps = append(steps, step)
+	}
+
+	wanted := map[string]bool{}
+	for _, element := range desired {
+		wanted[element] = true
+		count := running[element]
+		step := applyStep{kind: "element", name: element, action: "ok", detail: fmt.Sprintf("%d running", count), replicas: replicas[element]}
+		switch {
+		case count == 0:
+			step.action = "start"
+			step.detail = "not running"
+		case element == "fingerprint-tuner" && modelChanged:
+			step.action = "recreate"
+			step.detail = "model changed"
+		case step.replicas > 0 && step.replicas != count:
+			step.action = "scale"
+			step.detail = fmt.Sprintf("%d -> %d replicas", count, step.replicas)
+		}
+		if step.action == "start" && step.replicas > 1 {
+			step.detail = fmt.Sprintf("not running; %d replicas", step.replicas)
+		}
+		steps = append(steps, step)
+	}
+
+	extras := []string{}
+	for element := range running {
+		if wanted[element] || isProtectedCoreService(element) || element == "logingestion-receiver" {
+			continue
+		}
+		extras = append(extras, element)
+	}
+	sort.Strings(extras)
+	for _, element := range extras {
+		step := applyStep{kind: "element", name: element, action: "extra", detail: "not in stack file"}
+		if opts.Prune {
+			step.action = "remove"
+		}
+		steps = append(steps, step)
+	}
+
+	receiverSteps, err := planApplyReceivers(opts, stack)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(steps, receiverSteps...), missingCore, nil
+}
+
+func planApplyReceivers(opts ApplyOptions, stack *stackFile) ([]applyStep, error) {
+	rows, err := inspectReceiverContainers(opts.Project)
+	if err != nil {
+		return nil, err
+	}
+	live := map[string]ReceiverInstance{}
+	liveEnv := map[string]map[string]string{}
+	for _, row := range rows {
+		instance, ok := parseReceiverInstance(row, opts.Project)
+		if !ok {
+			continue
+		}
+		key := receiverStackKey(instance.ReceiverType, instance.HostPort)
+		env, err := receiverEnvFromContainer(instance.Name)
+		if err != nil {
+			return nil, fmt.Errorf("inspect receiver %s: %w", instance.Name, err)
+		}
+		instance.Mode = receiverModeFromEnv(env)
+		instance.ForwardRoute = env["FORWARD_ROUTE"]
+		if instance.ContainerPort == 0 {
+			instance.ContainerPort = blankInt(env["CONTAINER_PORT"], 0)
+		}
+		liveEnv[key] = env
+		live[key] = instance
+	}
+
+	steps := []applyStep{}
+	for i := range stack.Receivers {
+		receiver := &stack.Receivers[i]
+		key := receiverStackKey(receiver.Type, receiver.Port)
+		step := applyStep{kind: "receiver", name: key, action: "ok", detail: receiverStackDetail(receiver.Mode, receiver.ForwardRoute), receiver: receiver}
+		instance, exists := live[key]
+		if !exists {
+			step.action = "start"
+		} else {
+			changed, err := receiverDrift(instance, liveEnv[key], receiver, stack.Enterprise)
+			if err != nil {
+				return nil, err
+			}
+			if len(changed) > 0 {
+				step.action = "replace"
+				step.detail = strings.Join(changed, "; ")
+				step.instance = &instance
+			}
+		}
+		steps = append(steps, step)
+		delete(live, key)
+	}
+
+	extras := make([]string, 0, len(live))
+	for key := range live {
+		extras = append(extras, key)
+	}
+	sort.Strings(extras)
+	for _, key := range extras {
+		instance := live[key]
+		step := applyStep{kind: "receiver", name: key, action: "extra", detail: "not in stack file", instance: &instance}
+		if opts.Prune {
+			step.action = "remove"
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// receiverDrift lists every setting apply would write that differs from the
+// running receiver. The ingestion key is compared but never printed.
+func receiverDrift(instance ReceiverInstance, env map[string]string, receiver *stackReceiver, enterprise bool) ([]string, error) {
+	changed := []string{}
+	liveMode := blankDefault(instance.Mode, "local")
+	if liveMode != receiver.Mode || strings.TrimSpace(instance.ForwardRoute) != receiver.ForwardRoute {
+		changed = append(changed, fmt.Sprintf("%s -> %s", receiverStackDetail(liveMode, instance.ForwardRoute), receiverStackDetail(receiver.Mode, receiver.ForwardRoute)))
+	}
+
+	containerPort := receiver.ContainerPort
+	if containerPort <= 0 {
+		containerPort = 7004
+	}
+	if instance.ContainerPort != containerPort {
+		changed = append(changed, fmt.Sprintf("container port %d -> %d", instance.ContainerPort, containerPort))
+	}
+
+	wantKey, err := resolveIngestionKeyValue("", receiver.IngestionKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s receiver on port %d: %w", receiver.Type, receiver.Port, err)
+	}
+	if strings.TrimSpace(env["INGESTION_KEY"]) != wantKey {
+		switch {
+		case wantKey == "":
+			changed = append(changed, "ingestion key removed")
+		case strings.TrimSpace(env["INGESTION_KEY"]) == "":
+			changed = append(changed, "ingestion key added")
+		default:
+			changed = append(changed, "ingestion key changed")
+		}
+	}
+	if live, ok := env["HB_ENTERPRISE"]; ok && live != fmt.Sprintf("%t", enterprise) {
+		changed = append(changed, fmt.Sprintf("enterprise %s -> %t", live, enterprise))
+	}
+	return changed, nil
+}
+
+func receiverStackKey(receiverType string, port int) string {
+	return fmt.Sprintf("%s/%d", strings.ToLower(strings.TrimSpace(receiverType)), port)
+}
+
+func receiverStackDetail(mode string, forwardRoute string) string {
+	if strings.TrimSpace(forwardRoute) != "" {
+		return fmt.Sprintf("%s to %s", mode, forwardRoute)
+	}
+	return mode
+}
+
+func printApplyPlan(steps []applyStep) {
+	ui.Section("Plan")
+	rows := make([][]string, 0, len(steps))
+	for _, step := range steps {
+		action := step.action
+		switch step.action {
+		case "ok":
+			action = ui.Green("ok")
+		case "remove":
+			action = ui.Red(step.action)
+		case "extra":
+			action = ui.Yellow(step.action)
+		default:
+			action = ui.Cyan(step.action)
+		}
+		rows = append(rows, []string{step.kind, step.name, action, step.detail})
+	}
+	ui.Table([]string{"KIND", "NAME", "ACTION", "DETAIL"}, rows)
+}
+
+// convergeStack applies the plan in a fixed order: model, elements in
+// dependency order, replica counts, receivers, and finally pruning.
+func convergeStack(opts ApplyOptions, stack *stackFile, graph *elementGraph, steps []applyStep) error {
+	for _, step := range steps {
+		if step.kind == "model" && step.action == "set" {
+			ui.Section("Model")
+			if err := SaveFingerprintTunerModel(stack.Model); err != nil {
+				return err
+			}
+			os.Setenv("FINGERPRINT_TUNER_LLM_MODEL", stack.Model)
+			os.Setenv("OLLAMA_MODEL", stack.Model)
+			ui.Success("Saved fingerprint tuner model: %s", stack.Model)
+		}
+	}
+
+	// Stored counts are picked up by start, so elements started below come up
+	// with their replicas and later start and upgrade runs keep them.
+	if len(stack.Replicas) > 0 {
+		counts, err := loadReplicaCounts(opts.Project)
+		if err != nil {
+			return err
+		}
+		for name, replicas := range stack.Replicas {
+			setReplicaCount(counts, CanonicalElementName(name), replicas)
+		}
+		if err := saveReplicaCounts(opts.Project, counts); err != nil {
+			return err
+		}
+	}
+
+	start := []string{}
+	for _, step := range steps {
+		if step.kind == "element" && (step.action == "start" || step.action == "recreate") {
+			start = append(start, step.name)
+		}
+	}
+	if len(start) > 0 {
+		if err := Start(StartOptions{
+			Project:      opts.Project,
+			SecretsDir:   opts.SecretsDir,
+			Elements:     start,
+			Enterprise:   stack.Enterprise,
+			ReadyTimeout: opts.ReadyTimeout,
+		}); err != nil {
+			return err
+		}
+	}
+
+	scale := []applyStep{}
+	needsEnv := false
+	for _, step := range steps {
+		if step.kind != "element" {
+			continue
+		}
+		if step.action == "scale" {
+			scale = append(scale, step)
+			needsEnv = true
+		}
+		if step.action == "remove" {
+			needsEnv = true
+		}
+	}
+	env := blankLifecycleEnv(stack.Enterprise)
+	if needsEnv {
+		var err error
+		env, err = mongoLifecycleEnv(stack.Enterprise)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, step := range scale {
+		ui.Section("Scale " + step.name)
+		if err := scaleElement(opts.Project, env, step.name, step.replicas); err != nil {
+			return err
+		}
+	}
+
+	for _, step := range steps {
+		if step.kind != "receiver" {
+			continue
+		}
+		switch step.action {
+		case "replace":
+			if err := StopReceiver(ReceiverStopOptions{Project: opts.Project, ReceiverType: step.instance.ReceiverType, HostPort: step.instance.HostPort}); err != nil {
+				return err
+			}
+			if err := startStackReceiver(opts.Project, stack, step.receiver); err != nil {
+				return err
+			}
+		case "start":
+			if err := startStackReceiver(opts.Project, stack, step.receiver); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !opts.Prune {
+		return nil
+	}
+	for _, step := range steps {
+		if step.kind == "receiver" && step.action == "remove" {
+			if err := StopReceiver(ReceiverStopOptions{Project: opts.Project, ReceiverType: step.instance.ReceiverType, HostPort: step.instance.HostPort}); err != nil {
+				return err
+			}
+		}
+	}
+	remove := []string{}
+	for _, step := range steps {
+		if step.kind == "element" && step.action == "remove" {
+			remove = append(remove, step.name)
+		}
+	}
+	if len(remove) == 0 {
+		return nil
+	}
+	ordered, err := graph.reverseOrder(remove)
+	if err != nil {
+		return err
+	}
+	ui.Section("Prune")
+	for _, element := range ordered {
+		if err := stopElement(opts.Project, env, element, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startStackReceiver(project string, stack *stackFile, receiver *stackReceiver) error {
+	return StartReceiver(ReceiverStartOptions{
+		Project:          project,
+		ReceiverType:     strings.ToUpper(receiver.Type),
+		Mode:             receiver.Mode,
+		HostPort:         receiver.Port,
+		ContainerPort:    receiver.ContainerPort,
+		ForwardRoute:     receiver.ForwardRoute,
+		IngestionKeyFile: receiver.IngestionKeyFile,
+		Enterprise:       stack.Enterprise,
+	})
+}
@@ -2,12 +2,14 @@ package local
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
 
 	"github.com/herringbonedev/hbctl/internal/ui"
+	"github.com/herringbonedev/hbctl/internal/units"
 )
 
 const (
@@ -36,7 +38,12 @@ const (
 // CanonicalElementName returns the real compose service name used by
 // both core and enterprise deployments. Enterprise mode is selected by the
 // active compose files/images and HB_ENTERPRISE, not by renaming services.
+// Aliases declared in the catalog manifest take precedence over the built-in
+// table below.
 func CanonicalElementName(element string) string {
+	if name, ok := units.ResolveAlias(element); ok {
+		return name
+	}
 	switch strings.TrimSpace(element) {
 	case "auth", "herringbone-auth", "herringbone-auth-e":
 		return "herringbone-auth"
@@ -63,6 +70,18 @@ func ComposeFilesForElement(element string) []string {
 	element = CanonicalElementName(element)
 	files := []string{"-f", ComposeMongo}
 
+	// Catalog compose files are layered on top of the MongoDB compose file just
+	// like the built-in mapping, so secretsDirForProject and shouldStartElement
+	// keep working unchanged.
+	if declared, ok := units.ComposeFiles(element); ok {
+		for _, file := range declared {
+			if file != ComposeMongo {
+				files = append(files, "-f", file)
+			}
+		}
+		return files
+	}
+
 	switch element {
 	case "logingestion-receiver":
 		files = append(files, "-f", ComposeReceiver)
@@ -108,6 +127,17 @@ func ComposeFilesForElement(element string) []string {
 }
 
 func ComposeFilesForFullStack(enterprise bool) []string {
+	if units.HasCatalog() {
+		elements := []string{}
+		for _, element := range units.CatalogElementNames() {
+			if IsEnterpriseElement(element) && !enterprise {
+				continue
+			}
+			elements = append(elements, element)
+		}
+		return ComposeFilesForElements(elements)
+	}
+
 	candidates := []string{
 		ComposeMongo,
 		ComposeProxy,
@@ -173,6 +203,9 @@ func ComposeFilesForElements(elements []string) []string {
 // when the operator explicitly passes --enterprise. It intentionally does not
 // inspect container names or require a service-name suffix.
 func IsEnterpriseElement(element string) bool {
+	if enterprise, ok := units.IsEnterprise(CanonicalElementName(element)); ok {
+		return enterprise
+	}
 	switch CanonicalElementName(element) {
 	case "fingerprint-scoreset", "fingerprint-identifier", "fingerprint-tuner", "parser-enrichment":
 		return true
@@ -189,6 +222,25 @@ func AuthElementForMode(enterprise bool) string {
 	return "herringbone-auth"
 }
 
+// catalogFullStackElements returns the catalog elements that full-stack
+// lifecycle commands operate on. Receivers are always left to hbctl receiver.
+func catalogFullStackElements(enterprise bool, includeMongo bool) []string {
+	out := []string{}
+	for _, element := range units.CatalogElementNames() {
+		if element == "logingestion-receiver" {
+			continue
+		}
+		if element == "mongodb" && !includeMongo {
+			continue
+		}
+		if IsEnterpriseElement(element) && !enterprise {
+			continue
+		}
+		out = append(out, element)
+	}
+	return out
+}
+
 func filterEnterpriseElements(elements []string, enterprise bool) []string {
 	out := make([]string, 0, len(elements))
 	for _, element := range elements {
@@ -309,3 +361,51 @@ func composeConfigServices(composeArgs []string) ([]string, error) {
 	}
 	return services, nil
 }
+
+// composeConfigDocument is the subset of `docker compose config --format json`
+// hbctl reads. Compose normalizes short-form keys, so depends_on is always a
+// map keyed by service name.
+type composeConfigDocument struct {
+	Services map[string]composeConfigService `json:"services"`
+}
+
+type composeConfigService struct {
+	Image       string                     `json:"image"`
+	Environment map[string]*string         `json:"environment"`
+	Ports       []composeConfigPort        `json:"ports"`
+	DependsOn   map[string]json.RawMessage `json:"depends_on"`
+}
+
+type composeConfigPort struct {
+	Target    int    `json:"target"`
+	Published string `json:"published"`
+	Protocol  string `json:"protocol"`
+	HostIP    string `json:"host_ip"`
+}
+
+func composeConfigJSON(env map[string]string, composeArgs []string) (*composeConfigDocument, error) {
+	args := append([]string{}, composeArgs...)
+	args = append(args, "config", "--format", "json")
+
+	cmd := exec.Command("docker", append([]string{"compose"}, args...)...)
+	cmd.Env = composeConfigEnv()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg != "" {
+			return nil, fmt.Errorf("docker compose config failed: %s", msg)
+		}
+		return nil, err
+	}
+
+	var doc composeConfigDocument
+	if err := json.Unmarshal(out, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse docker compose config output: %w", err)
+	}
+	return &doc, nil
+}
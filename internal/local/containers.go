@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"sort"
@@ -232,7 +233,15 @@ func removeContainers(containers []herringboneContainer) error {
 	return runContainerCommand("rm", containers)
 }
 
+func restartContainers(containers []herringboneContainer) error {
+	return runContainerCommand("restart", containers)
+}
+
 func runContainerCommand(action string, containers []herringboneContainer) error {
+	return runContainerCommandTo(os.Stdout, action, containers)
+}
+
+func runContainerCommandTo(w io.Writer, action string, containers []herringboneContainer) error {
 	if len(containers) == 0 {
 		return nil
 	}
@@ -246,12 +255,15 @@ func runContainerCommand(action string, containers []herringboneContainer) error
 		}
 	}
 
-	ui.Command("docker %s", strings.Join(args, " "))
+	ui.FCommand(w, "docker %s", strings.Join(args, " "))
 	cmd := exec.Command("docker", args...)
 	cmd.Env = os.Environ()
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if w == io.Writer(os.Stdout) {
+		cmd.Stderr = os.Stderr
+		cmd.Stdin = os.Stdin
+	}
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("docker %s failed: %w", action, err)
 	}
@@ -287,6 +299,7 @@ type pruneContainerOptions struct {
 	IncludeProtected bool
 	Services         []string
 	QuietIfEmpty     bool
+	Out              io.Writer
 }
 
 func pruneStoppedContainers(opts pruneContainerOptions) error {
@@ -294,6 +307,10 @@ func pruneStoppedContainers(opts pruneContainerOptions) error {
 	if err != nil {
 		return err
 	}
+	out := opts.Out
+	if out == nil {
+		out = os.Stdout
+	}
 
 	allowed := map[string]bool{}
 	for _, service := range opts.Services {
@@ -322,31 +339,31 @@ func pruneStoppedContainers(opts pruneContainerOptions) error {
 
 	if len(toRemove) == 0 {
 		if !opts.QuietIfEmpty {
-			ui.Success("No stopped Herringbone containers to prune")
+			ui.FSuccess(out, "No stopped Herringbone containers to prune")
 		}
 	} else {
-		ui.Section("Prune stopped containers")
-		ui.Warn("Removing containers only. Docker volumes are not removed.")
+		ui.FSection(out, "Prune stopped containers")
+		ui.FWarn(out, "Removing containers only. Docker volumes are not removed.")
 		tableRows := make([][]string, 0, len(toRemove))
 		for _, container := range toRemove {
 			tableRows = append(tableRows, []string{container.Service, container.Project, container.State, container.Name})
 		}
-		ui.Table([]string{"SERVICE", "PROJECT", "STATE", "NAME"}, tableRows)
-		ui.Step("Removing stopped container(s)")
-		if err := removeContainers(toRemove); err != nil {
+		ui.FTable(out, []string{"SERVICE", "PROJECT", "STATE", "NAME"}, tableRows)
+		ui.FStep(out, "Removing stopped container(s)")
+		if err := runContainerCommandTo(out, "rm", toRemove); err != nil {
 			return err
 		}
-		ui.Success("Pruned %d stopped container(s)", len(toRemove))
+		ui.FSuccess(out, "Pruned %d stopped container(s)", len(toRemove))
 	}
 
 	if len(protectedSkipped) > 0 && !opts.QuietIfEmpty {
-		ui.Section("Protected core skipped")
+		ui.FSection(out, "Protected core skipped")
 		tableRows := make([][]string, 0, len(protectedSkipped))
 		for _, container := range protectedSkipped {
 			tableRows = append(tableRows, []string{container.Service, container.Project, container.State, container.Name})
 		}
-		ui.Table([]string{"SERVICE", "PROJECT", "STATE", "NAME"}, tableRows)
-		ui.Info("Use hbctl prune --core to remove stopped protected core containers. Volumes are still never removed by hbctl prune.")
+		ui.FTable(out, []string{"SERVICE", "PROJECT", "STATE", "NAME"}, tableRows)
+		ui.FInfo(out, "Use hbctl prune --core to remove stopped protected core containers. Volumes are still never removed by hbctl prune.")
 	}
 
 	return nil
@@ -0,0 +1,427 @@
+package local
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/herringbonedev/hbctl/internal/ui"
+	"github.com/herringbonedev/hbctl/internal/units"
+)
+
+const composeConfigHashLabel = "com.docker.compose.config-hash"
+
+type DiffOptions struct {
+	Project    string
+	Element    string
+	Unit       string
+	Enterprise bool
+	JSON       bool
+	Out        io.Writer
+}
+
+type ElementDrift struct {
+	Element     string   `json:"element"`
+	State       string   `json:"state"`
+	Containers  []string `json:"containers,omitempty"`
+	Differences []string `json:"differences,omitempty"`
+	Fix         string   `json:"fix,omitempty"`
+}
+
+type dockerInspectDrift struct {
+	Image  string `json:"Image"`
+	Config struct {
+		Image  string            `json:"Image"`
+		Env    []string          `json:"Env"`
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+	HostConfig struct {
+		PortBindings map[string][]struct {
+			HostIP   string `json:"HostIp"`
+			HostPort string `json:"HostPort"`
+		} `json:"PortBindings"`
+	} `json:"HostConfig"`
+}
+
+// Diff compares running containers with what the compose files and .env would
+// produce now. Each element is reported as in sync, drifted (with the keys that
+// differ), or missing, along with the hbctl command that would fix it.
+func Diff(opts DiffOptions) error {
+	out := opts.Out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	containers, err := listHerringboneContainers(opts.Project, false)
+	if err != nil {
+		return err
+	}
+	byElement := map[string][]herringboneContainer{}
+	for _, container := range containers {
+		if strings.Contains(container.Project, "-receiver-") {
+			continue
+		}
+		element := CanonicalElementName(container.Service)
+		byElement[element] = append(byElement[element], container)
+	}
+
+	targets, err := diffTargets(opts, byElement)
+	if err != nil {
+		return err
+	}
+
+	if !opts.JSON {
+		ui.FHeader(out, "Herringbone diff")
+		ui.FKeyValues(out, [][2]string{{"project", opts.Project}, {"elements", fmt.Sprintf("%d", len(targets))}})
+	}
+
+	// Interpolation needs the same environment lifecycle commands use; without
+	// stored credentials the comparison still runs but Mongo variables show as
+	// drifted.
+	env, err := mongoLifecycleEnv(opts.Enterprise)
+	if err != nil {
+		if !opts.JSON {
+			ui.FWarn(out, "Comparing without MongoDB credentials: %v", err)
+		}
+		env = blankLifecycleEnv(opts.Enterprise)
+	}
+
+	report := make([]ElementDrift, 0, len(targets))
+	for _, element := range targets {
+		report = append(report, diffElement(opts.Project, env, element, preferProjectContainers(opts.Project, byElement[element])))
+	}
+
+	if opts.JSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	rows := make([][]string, 0, len(report))
+	fixes := []string{}
+	inSync := 0
+	for _, drift := range report {
+		state := drift.State
+		switch drift.State {
+		case "in sync":
+			inSync++
+			state = ui.Green(state)
+		case "drifted":
+			state = ui.Yellow(state)
+		default:
+			state = ui.Red(state)
+		}
+		rows = append(rows, []string{drift.Element, state, strings.Join(drift.Differences, "; ")})
+		if drift.Fix != "" {
+			fixes = append(fixes, drift.Fix)
+		}
+	}
+	ui.FTable(out, []string{"ELEMENT", "STATE", "DIFFERENCES"}, rows)
+
+	if len(fixes) == 0 {
+		ui.FSuccess(out, "%d element(s) in sync", inSync)
+		return nil
+	}
+	ui.FSection(out, "Suggested fixes")
+	for _, fix := range fixes {
+		ui.FCommand(out, "%s", fix)
+	}
+	return nil
+}
+
+// diffTargets returns the requested element or unit, or every running element
+// when neither is given.
+func diffTargets(opts DiffOptions, running map[string][]herringboneContainer) ([]string, error) {
+	var candidates []string
+	switch {
+	case strings.TrimSpace(opts.Element) != "":
+		element := ElementForMode(opts.Element, opts.Enterprise)
+		if element == "logingestion-receiver" {
+			return nil, fmt.Errorf("logingestion-receiver is managed separately; use hbctl receiver list")
+		}
+		if IsEnterpriseElement(element) && !opts.Enterprise {
+			return nil, fmt.Errorf("%s is an enterprise service; pass --enterprise to diff it", element)
+		}
+		return []string{element}, nil
+	case strings.TrimSpace(opts.Unit) != "":
+		candidates = units.UnitElements[strings.TrimSpace(opts.Unit)]
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("unknown unit: %s", opts.Unit)
+		}
+	default:
+		for element := range running {
+			candidates = append(candidates, element)
+		}
+		sort.Strings(candidates)
+	}
+
+	targets := []string{}
+	for _, element := range uniqueStrings(candidates) {
+		element = CanonicalElementName(element)
+		if element == "logingestion-receiver" {
+			continue
+		}
+		if IsEnterpriseElement(element) && !opts.Enterprise && len(running[element]) == 0 {
+			continue
+		}
+		if start, _, err := shouldStartElement(element, ComposeFilesForElement(element)); err != nil || !start {
+			continue
+		}
+		targets = append(targets, element)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no running elements with compose files to compare")
+	}
+	return targets, nil
+}
+
+func diffElement(project string, env map[string]string, element string, containers []herringboneContainer) ElementDrift {
+	drift := ElementDrift{Element: element, State: "in sync"}
+	if len(containers) == 0 {
+		drift.State = "missing"
+		drift.Fix = elementFixCommand("start", element)
+		return drift
+	}
+
+	// Digests pinned in hbctl.lock are part of the desired state.
+	composeFiles := withLockOverrides(ComposeFilesForElement(element))
+	composeArgs := append([]string{"-p", project}, composeFiles...)
+	service, err := resolveComposeServiceName(composeFiles, element)
+	if err != nil {
+		drift.State = "unknown"
+		drift.Differences = []string{err.Error()}
+		return drift
+	}
+	doc, err := composeConfigJSON(env, composeArgs)
+	if err != nil {
+		drift.State = "unknown"
+		drift.Differences = []string{err.Error()}
+		return drift
+	}
+	expected, ok := doc.Services[service]
+	if !ok {
+		drift.State = "unknown"
+		drift.Differences = []string{fmt.Sprintf("compose service %s not found", service)}
+		return drift
+	}
+	expectedHash := composeConfigHash(env, composeArgs, service)
+	expectedImageID := dockerImageID(expected.Image)
+
+	seen := map[string]bool{}
+	for _, container := range containers {
+		drift.Containers = append(drift.Containers, container.Name)
+		for _, difference := range containerDrift(container, expected, expectedHash, expectedImageID) {
+			if !seen[difference] {
+				seen[difference] = true
+				drift.Differences = append(drift.Differences, difference)
+			}
+		}
+	}
+
+	if len(drift.Differences) > 0 {
+		drift.State = "drifted"
+		drift.Fix = elementFixCommand("upgrade", element)
+	}
+	return drift
+}
+
+func containerDrift(container herringboneContainer, expected composeConfigService, expectedHash string, expectedImageID string) []string {
+	target := blankDefault(container.ID, container.Name)
+	cmd := exec.Command("docker", "inspect", target, "--format", "{{json .}}")
+	cmd.Env = os.Environ()
+	output, err := cmd.Output()
+	if err != nil {
+		return []string{fmt.Sprintf("inspect %s failed: %v", container.Name, err)}
+	}
+	var live dockerInspectDrift
+	if err := json.Unmarshal(bytes.TrimSpace(output), &live); err != nil {
+		return []string{fmt.Sprintf("inspect %s failed: %v", container.Name, err)}
+	}
+
+	differences := []string{}
+	if expected.Image != "" && live.Config.Image != expected.Image {
+		differences = append(differences, fmt.Sprintf("image %s -> %s", live.Config.Image, expected.Image))
+	} else if expectedImageID != "" && live.Image != expectedImageID {
+		differences = append(differences, fmt.Sprintf("image digest %s -> %s", shortImageID(live.Image), shortImageID(expectedImageID)))
+	}
+
+	liveEnv := map[string]string{}
+	for _, entry := range live.Config.Env {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) == 2 {
+			liveEnv[parts[0]] = parts[1]
+		}
+	}
+	keys := make([]string, 0, len(expected.Environment))
+	for key := range expected.Environment {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		want := ""
+		if value := expected.Environment[key]; value != nil {
+			want = *value
+		}
+		have, ok := liveEnv[key]
+		switch {
+		case !ok:
+			differences = append(differences, fmt.Sprintf("env %s added (%s)", key, redactEnvValue(key, want)))
+		case have != want:
+			differences = append(differences, fmt.Sprintf("env %s %s -> %s", key, redactEnvValue(key, have), redactEnvValue(key, want)))
+		}
+	}
+
+	wantPorts := map[string]bool{}
+	for _, port := range expected.Ports {
+		if strings.TrimSpace(port.Published) == "" {
+			continue
+		}
+		wantPorts[fmt.Sprintf("%s:%d/%s", port.Published, port.Target, blankDefault(port.Protocol, "tcp"))] = true
+	}
+	havePorts := map[string]bool{}
+	for containerPort, bindings := range live.HostConfig.PortBindings {
+		targetPort, protocol, _ := strings.Cut(containerPort, "/")
+		for _, binding := range bindings {
+			if strings.TrimSpace(binding.HostPort) == "" {
+				continue
+			}
+			havePorts[fmt.Sprintf("%s:%s/%s", binding.HostPort, targetPort, blankDefault(protocol, "tcp"))] = true
+		}
+	}
+	for _, port := range sortedKeys(wantPorts) {
+		if !havePorts[port] {
+			differences = append(differences, "port "+port+" added")
+		}
+	}
+	for _, port := range sortedKeys(havePorts) {
+		if !wantPorts[port] {
+			differences = append(differences, "port "+port+" removed")
+		}
+	}
+
+	// The hash covers everything above plus volumes, networks, and the rest of
+	// the service definition, so it is reported only when nothing more specific
+	// explains the difference.
+	if len(differences) == 0 && expectedHash != "" {
+		if liveHash := strings.TrimSpace(live.Config.Labels[composeConfigHashLabel]); liveHash != "" && liveHash != expectedHash {
+			differences = append(differences, "compose config hash changed")
+		}
+	}
+	return differences
+}
+
+func composeConfigHash(env map[string]string, composeArgs []string, service string) string {
+	args := append([]string{"compose"}, composeArgs...)
+	args = append(args, "config", "--hash", service)
+	cmd := exec.Command("docker", args...)
+	cmd.Env = composeConfigEnv()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == service {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+func dockerImageID(image string) string {
+	if strings.TrimSpace(image) == "" {
+		return ""
+	}
+	cmd := exec.Command("docker", "image", "inspect", "--format", "{{.Id}}", image)
+	cmd.Env = os.Environ()
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func shortImageID(id string) string {
+	id = strings.TrimPrefix(strings.TrimSpace(id), "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// redactEnvValue hides values of variables that look like credentials, and
+// the user info of connection strings such as MONGO_URI.
+func redactEnvValue(key string, value string) string {
+	upper := strings.ToUpper(key)
+	for _, marker := range []string{"PASS", "SECRET", "TOKEN", "KEY", "PRIVATE", "CREDENTIAL"} {
+		if strings.Contains(upper, marker) {
+			return "<redacted>"
+		}
+	}
+	if value == "" {
+		return `""`
+	}
+	for _, suffix := range []string{"URI", "URL", "DSN"} {
+		if strings.HasSuffix(upper, suffix) {
+			return redactURLUserinfo(value)
+		}
+	}
+	return value
+}
+
+// redactURLUserinfo masks the user and password of a URL, keeping the rest
+// so host and database changes still show.
+func redactURLUserinfo(value string) string {
+	scheme, rest, ok := strings.Cut(value, "://")
+	if !ok {
+		return value
+	}
+	authority := rest
+	if end := strings.IndexAny(rest, "/?#"); end >= 0 {
+		authority = rest[:end]
+	}
+	if !strings.Contains(authority, "@") {
+		return value
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.User == nil {
+		// Multi-host MongoDB URIs do not always parse; hide everything
+		// before the last @ of the authority.
+		return scheme + "://<redacted>@" + rest[strings.LastIndex(authority, "@")+1:]
+	}
+	if _, hasPassword := parsed.User.Password(); hasPassword {
+		parsed.User = url.UserPassword("redacted", "redacted")
+	} else {
+		parsed.User = url.User("redacted")
+	}
+	return parsed.String()
+}
+
+func sortedKeys(values map[string]bool) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func elementFixCommand(action string, element string) string {
+	if element == "mongodb" && action == "upgrade" {
+		return "# mongodb is protected: back up the database and recreate it manually"
+	}
+	command := fmt.Sprintf("hbctl %s --element %s", action, element)
+	if IsEnterpriseElement(element) {
+		command += " --enterprise"
+	}
+	return command
+}
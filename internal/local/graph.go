@@ -0,0 +1,440 @@
+package local
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/herringbonedev/hbctl/internal/ui"
+	"github.com/herringbonedev/hbctl/internal/units"
+)
+
+type GraphOptions struct {
+	Unit       string
+	Element    string
+	Enterprise bool
+	Format     string
+	Out        io.Writer
+}
+
+// elementGraph is the element dependency graph that orders lifecycle
+// operations. Edges come from the element catalog and, when docker compose can
+// render the installed files, from compose depends_on.
+type elementGraph struct {
+	nodes      []string
+	deps       map[string][]string
+	sources    map[string]string
+	composeErr error
+}
+
+type graphEdge struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Source string `json:"source"`
+}
+
+type graphNode struct {
+	Name       string `json:"name"`
+	Unit       string `json:"unit,omitempty"`
+	Enterprise bool   `json:"enterprise"`
+	Protected  bool   `json:"protected"`
+}
+
+type graphDocument struct {
+	Catalog    string      `json:"catalog"`
+	Nodes      []graphNode `json:"nodes"`
+	Edges      []graphEdge `json:"edges"`
+	StartOrder []string    `json:"start_order"`
+}
+
+func graphEdgeKey(from, to string) string {
+	return from + "\x00" + to
+}
+
+func buildElementGraph(env map[string]string) *elementGraph {
+	g := &elementGraph{deps: map[string][]string{}, sources: map[string]string{}}
+	known := map[string]bool{}
+	addNode := func(name string) {
+		name = CanonicalElementName(name)
+		if name == "" || known[name] {
+			return
+		}
+		known[name] = true
+		g.nodes = append(g.nodes, name)
+	}
+
+	if units.HasCatalog() {
+		for _, name := range units.CatalogElementNames() {
+			addNode(name)
+		}
+	} else {
+		for _, name := range []string{"herringbone-proxy", "mongodb", "herringbone-auth"} {
+			addNode(name)
+		}
+		for _, element := range units.AllElements {
+			addNode(element.Name)
+		}
+	}
+	extra := []string{}
+	for name := range units.ElementDependencies {
+		if !known[CanonicalElementName(name)] {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		addNode(name)
+	}
+
+	addEdge := func(from, to, source string) {
+		from = CanonicalElementName(from)
+		to = CanonicalElementName(to)
+		if from == to || !known[from] || !known[to] {
+			return
+		}
+		key := graphEdgeKey(from, to)
+		if existing, ok := g.sources[key]; ok {
+			if existing != source && !strings.Contains(existing, source) {
+				g.sources[key] = existing + "+" + source
+			}
+			return
+		}
+		g.sources[key] = source
+		g.deps[from] = append(g.deps[from], to)
+	}
+
+	for _, name := range g.nodes {
+		for _, dep := range units.ElementDependencies[name] {
+			addEdge(name, dep, "catalog")
+		}
+	}
+
+	composeArgs := ComposeFilesForFullStack(true)
+	if len(composeArgs) == 0 {
+		return g
+	}
+	doc, err := composeConfigJSON(env, composeArgs)
+	if err != nil {
+		g.composeErr = err
+		return g
+	}
+	services := make([]string, 0, len(doc.Services))
+	for service := range doc.Services {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	for _, service := range services {
+		deps := make([]string, 0, len(doc.Services[service].DependsOn))
+		for dep := range doc.Services[service].DependsOn {
+			deps = append(deps, dep)
+		}
+		sort.Strings(deps)
+		for _, dep := range deps {
+			addEdge(service, dep, "compose")
+		}
+	}
+	return g
+}
+
+// closure returns roots plus every dependency reachable from them. Dependencies
+// rejected by include are left out and not traversed further.
+func (g *elementGraph) closure(roots []string, include func(string) bool) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	var visit func(string)
+	visit = func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		out = append(out, name)
+		for _, dep := range g.deps[name] {
+			if include == nil || include(dep) {
+				visit(dep)
+			}
+		}
+	}
+	for _, root := range roots {
+		visit(CanonicalElementName(root))
+	}
+	return out
+}
+
+// order sorts elements so every element comes after the dependencies that are
+// also in the list. Ties keep the caller's order, so the result is stable.
+func (g *elementGraph) order(elements []string) ([]string, error) {
+	position := map[string]int{}
+	list := []string{}
+	for _, element := range elements {
+		element = CanonicalElementName(element)
+		if _, ok := position[element]; ok || element == "" {
+			continue
+		}
+		position[element] = len(list)
+		list = append(list, element)
+	}
+
+	pending := map[string]int{}
+	dependents := map[string][]string{}
+	for _, element := range list {
+		for _, dep := range g.deps[element] {
+			if _, ok := position[dep]; !ok {
+				continue
+			}
+			pending[element]++
+			dependents[dep] = append(dependents[dep], element)
+		}
+	}
+
+	ready := []string{}
+	for _, element := range list {
+		if pending[element] == 0 {
+			ready = append(ready, element)
+		}
+	}
+
+	out := make([]string, 0, len(list))
+	for len(ready) > 0 {
+		sort.SliceStable(ready, func(i, j int) bool { return position[ready[i]] < position[ready[j]] })
+		next := ready[0]
+		ready = ready[1:]
+		out = append(out, next)
+		for _, dependent := range dependents[next] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(out) != len(list) {
+		cycle := []string{}
+		for _, element := range list {
+			if pending[element] > 0 {
+				cycle = append(cycle, element)
+			}
+		}
+		return nil, fmt.Errorf("element dependency cycle between: %s", strings.Join(cycle, ", "))
+	}
+	return out, nil
+}
+
+// dependents returns every element that transitively depends on root.
+func (g *elementGraph) dependents(root string) []string {
+	root = CanonicalElementName(root)
+	out := []string{}
+	seen := map[string]bool{root: true}
+	queue := []string{root}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, node := range g.nodes {
+			if seen[node] {
+				continue
+			}
+			for _, dep := range g.deps[node] {
+				if dep == current {
+					seen[node] = true
+					out = append(out, node)
+					queue = append(queue, node)
+					break
+				}
+			}
+		}
+	}
+	return out
+}
+
+// reverseOrder is the stop order: dependents before their dependencies.
+func (g *elementGraph) reverseOrder(elements []string) ([]string, error) {
+	ordered, err := g.order(elements)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(ordered)-1; i < j; i, j = i+1, j-1 {
+		ordered[i], ordered[j] = ordered[j], ordered[i]
+	}
+	return ordered, nil
+}
+
+func (g *elementGraph) edges(nodes []string) []graphEdge {
+	in := map[string]bool{}
+	for _, node := range nodes {
+		in[node] = true
+	}
+	out := []graphEdge{}
+	for _, from := range nodes {
+		for _, to := range g.deps[from] {
+			if in[to] {
+				out = append(out, graphEdge{From: from, To: to, Source: g.sources[graphEdgeKey(from, to)]})
+			}
+		}
+	}
+	return out
+}
+
+// startDependencyClosure expands a start target with the application
+// elements it depends on, in start order. Protected core is handled by the
+// full-stack start path, receivers by hbctl receiver, and enterprise
+// dependencies are skipped outside enterprise mode.
+func startDependencyClosure(graph *elementGraph, targets []string, enterprise bool) ([]string, error) {
+	explicit := map[string]bool{}
+	for _, target := range targets {
+		explicit[CanonicalElementName(target)] = true
+	}
+	elements := graph.closure(targets, func(dep string) bool {
+		if explicit[dep] {
+			return true
+		}
+		if isProtectedCoreService(dep) || dep == "logingestion-receiver" {
+			return false
+		}
+		if IsEnterpriseElement(dep) && !enterprise {
+			ui.Skip("%s: enterprise dependency requires --enterprise", dep)
+			return false
+		}
+		return true
+	})
+	return graph.order(elements)
+}
+
+// Graph renders the element dependency graph for hbctl graph.
+func Graph(opts GraphOptions) error {
+	out := opts.Out
+	graph := buildElementGraph(blankLifecycleEnv(opts.Enterprise))
+
+	nodes := graph.nodes
+	switch {
+	case strings.TrimSpace(opts.Element) != "":
+		element := CanonicalElementName(opts.Element)
+		if !graphHasNode(graph, element) {
+			return fmt.Errorf("unknown element: %s", opts.Element)
+		}
+		nodes = graph.closure([]string{element}, nil)
+	case strings.TrimSpace(opts.Unit) != "":
+		members := units.UnitElements[strings.TrimSpace(opts.Unit)]
+		if len(members) == 0 {
+			return fmt.Errorf("unknown unit: %s", opts.Unit)
+		}
+		nodes = graph.closure(members, nil)
+	}
+	if !opts.Enterprise && strings.TrimSpace(opts.Element) == "" {
+		filtered := []string{}
+		for _, node := range nodes {
+			if !IsEnterpriseElement(node) {
+				filtered = append(filtered, node)
+			}
+		}
+		nodes = filtered
+	}
+
+	order, err := graph.order(nodes)
+	if err != nil {
+		return err
+	}
+
+	doc := graphDocument{Catalog: units.CatalogSource(), Edges: graph.edges(order), StartOrder: order}
+	unitOf := map[string]string{}
+	for _, element := range units.AllElements {
+		unitOf[CanonicalElementName(element.Name)] = element.Unit
+	}
+	for unit, members := range units.UnitElements {
+		for _, member := range members {
+			if _, ok := unitOf[CanonicalElementName(member)]; !ok {
+				unitOf[CanonicalElementName(member)] = unit
+			}
+		}
+	}
+	for _, node := range order {
+		doc.Nodes = append(doc.Nodes, graphNode{Name: node, Unit: unitOf[node], Enterprise: IsEnterpriseElement(node), Protected: isProtectedCoreService(node)})
+	}
+
+	switch strings.ToLower(strings.TrimSpace(opts.Format)) {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	case "dot":
+		writeGraphDot(out, doc)
+		return nil
+	case "mermaid":
+		writeGraphMermaid(out, doc)
+		return nil
+	case "", "table":
+		ui.FHeader(out, "Herringbone element graph")
+		ui.FKeyValues(out, [][2]string{{"catalog", doc.Catalog}})
+		if graph.composeErr != nil {
+			ui.FWarn(out, "compose depends_on not included: %v", graph.composeErr)
+		}
+		rows := make([][]string, 0, len(doc.Nodes))
+		for i, node := range doc.Nodes {
+			deps := []string{}
+			for _, edge := range doc.Edges {
+				if edge.From == node.Name {
+					deps = append(deps, fmt.Sprintf("%s (%s)", edge.To, edge.Source))
+				}
+			}
+			rows = append(rows, []string{fmt.Sprintf("%d", i+1), node.Name, node.Unit, strings.Join(deps, ", ")})
+		}
+		ui.FTable(out, []string{"ORDER", "ELEMENT", "UNIT", "DEPENDS ON"}, rows)
+		return nil
+	default:
+		return fmt.Errorf("unsupported graph format %q; use table, dot, mermaid, or json", opts.Format)
+	}
+}
+
+func graphHasNode(graph *elementGraph, name string) bool {
+	for _, node := range graph.nodes {
+		if node == name {
+			return true
+		}
+	}
+	return false
+}
+
+func writeGraphDot(w io.Writer, doc graphDocument) {
+	fmt.Fprintln(w, "digraph herringbone {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	for _, node := range doc.Nodes {
+		attrs := []string{}
+		styles := []string{}
+		if node.Protected {
+			attrs = append(attrs, "shape=box")
+			styles = append(styles, "bold")
+		}
+		if node.Enterprise {
+			styles = append(styles, "dashed")
+		}
+		if len(styles) > 0 {
+			attrs = append(attrs, fmt.Sprintf("style=%q", strings.Join(styles, ",")))
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(w, "  %q [%s];\n", node.Name, strings.Join(attrs, ","))
+		} else {
+			fmt.Fprintf(w, "  %q;\n", node.Name)
+		}
+	}
+	for _, edge := range doc.Edges {
+		fmt.Fprintf(w, "  %q -> %q [label=%q];\n", edge.From, edge.To, edge.Source)
+	}
+	fmt.Fprintln(w, "}")
+}
+
+func writeGraphMermaid(w io.Writer, doc graphDocument) {
+	id := func(name string) string {
+		return strings.NewReplacer("-", "_", ".", "_").Replace(name)
+	}
+	fmt.Fprintln(w, "graph LR")
+	for _, node := range doc.Nodes {
+		if node.Protected {
+			fmt.Fprintf(w, "  %s[[%s]]\n", id(node.Name), node.Name)
+		} else {
+			fmt.Fprintf(w, "  %s[%s]\n", id(node.Name), node.Name)
+		}
+	}
+	for _, edge := range doc.Edges {
+		fmt.Fprintf(w, "  %s -->|%s| %s\n", id(edge.From), edge.Source, id(edge.To))
+	}
+}
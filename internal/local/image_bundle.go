@@ -0,0 +1,543 @@
+package local
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/herringbonedev/hbctl/internal/ui"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ImageBundleVersion is the newest image bundle format this hbctl reads.
+const ImageBundleVersion = 1
+
+const (
+	imageBundleManifestName = "manifest.json"
+	imageBundleImagesName   = "images.tar"
+	ollamaDefaultModelsDir  = "/root/.ollama/models"
+	ollamaDefaultRegistry   = "registry.ollama.ai"
+)
+
+// imageBundleManifest is the first entry of a bundle. Images are checked by
+// ID after loading; every other entry is checked against Files while it is
+// read.
+type imageBundleManifest struct {
+	Version int            `json:"version"`
+	Created time.Time      `json:"created"`
+	Images  []bundledImage `json:"images"`
+	Models  []bundledModel `json:"models,omitempty"`
+	Files   []bundledFile  `json:"files"`
+}
+
+type bundledImage struct {
+	Ref      string   `json:"ref"`
+	ID       string   `json:"id"`
+	Digest   string   `json:"digest,omitempty"`
+	Services []string `json:"services,omitempty"`
+}
+
+type bundledModel struct {
+	Name     string   `json:"name"`
+	Manifest string   `json:"manifest"`
+	Blobs    []string `json:"blobs"`
+}
+
+type bundledFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type ollamaManifest struct {
+	Config ollamaLayer   `json:"config"`
+	Layers []ollamaLayer `json:"layers"`
+}
+
+type ollamaLayer struct {
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+}
+
+// SaveImageBundle writes every image the unit or full stack references to a
+// zstd-compressed tar at Bundle, with a manifest of image IDs and file
+// checksums. With Models set it also adds the ollama image and the weights of
+// the fingerprint tuner model from the running ollama container.
+func SaveImageBundle(opts ImagesOptions) error {
+	ui.Header("Herringbone image save")
+	if strings.TrimSpace(opts.Bundle) == "" {
+		return fmt.Errorf("an output path is required")
+	}
+
+	referenced, err := composeImageRefs(opts.Project, opts.Unit, opts.Enterprise)
+	if err != nil {
+		return err
+	}
+	model := ""
+	if opts.Models {
+		ollama, err := ollamaImageRefs(opts.Project)
+		if err != nil {
+			return err
+		}
+		for ref, services := range ollama {
+			referenced[ref] = unionServices(referenced[ref], services)
+		}
+		model = ResolveFingerprintTunerModel()
+	}
+	refs := make([]string, 0, len(referenced))
+	for ref := range referenced {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	if len(refs) == 0 {
+		return fmt.Errorf("no images referenced by the compose files")
+	}
+	ui.KeyValues([][2]string{{"bundle", opts.Bundle}, {"images", fmt.Sprintf("%d", len(refs))}, {"model", blankDefault(model, "-")}})
+
+	manifest := imageBundleManifest{Version: ImageBundleVersion, Created: time.Now().UTC().Truncate(time.Second)}
+	missing := []string{}
+	for _, ref := range refs {
+		id := dockerImageID(ref)
+		if id == "" {
+			missing = append(missing, ref)
+			continue
+		}
+		digest := ""
+		if digests := imageRepoDigests(ref); len(digests) > 0 {
+			digest = digests[0]
+		}
+		manifest.Images = append(manifest.Images, bundledImage{Ref: ref, ID: id, Digest: digest, Services: referenced[ref]})
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%d image(s) are not on this host: %s; run hbctl images pull first", len(missing), strings.Join(missing, ", "))
+	}
+
+	work, err := os.MkdirTemp(filepath.Dir(opts.Bundle), ".hbctl-images-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(work)
+
+	ui.Step("Saving %d image(s)", len(refs))
+	cmd := exec.Command("docker", append([]string{"save", "-o", filepath.Join(work, imageBundleImagesName)}, refs...)...)
+	cmd.Env = os.Environ()
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("docker save failed: %s", blankDefault(strings.TrimSpace(string(out)), err.Error()))
+	}
+	names := []string{imageBundleImagesName}
+
+	if model != "" {
+		ui.Step("Copying model %s from ollama", model)
+		saved, err := saveOllamaModel(opts.Project, model, work)
+		if err != nil {
+			return err
+		}
+		manifest.Models = append(manifest.Models, saved)
+		names = append(names, saved.Manifest)
+		names = append(names, saved.Blobs...)
+	}
+
+	for _, name := range names {
+		size, sum, err := fileSizeSHA256(filepath.Join(work, filepath.FromSlash(name)))
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, bundledFile{Name: name, Size: size, SHA256: sum})
+	}
+
+	ui.Step("Compressing bundle")
+	if err := writeImageBundle(opts.Bundle, work, manifest); err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(manifest.Images))
+	for _, image := range manifest.Images {
+		rows = append(rows, []string{image.Ref, "sha256:" + shortImageID(image.ID), strings.Join(image.Services, ", ")})
+	}
+	ui.Table([]string{"IMAGE", "ID", "COMPOSE SERVICES"}, rows)
+	info, err := os.Stat(opts.Bundle)
+	if err != nil {
+		return err
+	}
+	ui.Success("Saved %d image(s) and %d model(s) to %s (%s)", len(manifest.Images), len(manifest.Models), opts.Bundle, ui.Size(info.Size()))
+	ui.Info("Load it on the offline host with hbctl images load %s", filepath.Base(opts.Bundle))
+	return nil
+}
+
+// LoadImageBundle checks every entry of the bundle at Bundle against its
+// manifest before loading anything, then loads the images and confirms each
+// one has the ID recorded when it was saved. Model weights are copied into
+// the running ollama container.
+func LoadImageBundle(opts ImagesOptions) error {
+	ui.Header("Herringbone image load")
+
+	file, err := os.Open(opts.Bundle)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	decoder, err := zstd.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer decoder.Close()
+	reader := tar.NewReader(decoder)
+
+	header, err := reader.Next()
+	if err != nil || header.Name != imageBundleManifestName {
+		return fmt.Errorf("%s is not an hbctl image bundle: %s is missing", opts.Bundle, imageBundleManifestName)
+	}
+	var manifest imageBundleManifest
+	if err := json.NewDecoder(io.LimitReader(reader, 16<<20)).Decode(&manifest); err != nil {
+		return fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+	if manifest.Version <= 0 || manifest.Version > ImageBundleVersion {
+		return fmt.Errorf("bundle manifest version %d is not supported; this hbctl reads up to version %d", manifest.Version, ImageBundleVersion)
+	}
+	expected := map[string]bundledFile{}
+	for _, entry := range manifest.Files {
+		if !validBundleName(entry.Name) {
+			return fmt.Errorf("bundle manifest lists an unsafe path: %q", entry.Name)
+		}
+		expected[entry.Name] = entry
+	}
+	ui.KeyValues([][2]string{
+		{"bundle", opts.Bundle},
+		{"created", manifest.Created.Format(time.RFC3339)},
+		{"images", fmt.Sprintf("%d", len(manifest.Images))},
+		{"models", fmt.Sprintf("%d", len(manifest.Models))},
+	})
+
+	if err := os.MkdirAll(".hbctl", 0o755); err != nil {
+		return err
+	}
+	work, err := os.MkdirTemp(".hbctl", "images-load-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(work)
+
+	ui.Section("Verify bundle")
+	seen := map[string]bool{}
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read bundle: %w", err)
+		}
+		want, ok := expected[header.Name]
+		if !ok || header.Typeflag != tar.TypeReg || seen[header.Name] {
+			return fmt.Errorf("bundle contains %s, which its manifest does not list", header.Name)
+		}
+		seen[header.Name] = true
+		size, sum, err := extractBundleFile(reader, filepath.Join(work, filepath.FromSlash(header.Name)))
+		if err != nil {
+			return err
+		}
+		if size != want.Size || sum != want.SHA256 {
+			return fmt.Errorf("%s does not match the bundle manifest (sha256 %s, expected %s); the bundle is damaged", header.Name, sum, want.SHA256)
+		}
+	}
+	for name := range expected {
+		if !seen[name] {
+			return fmt.Errorf("bundle is missing %s", name)
+		}
+	}
+	ui.Success("%d file(s) match the bundle manifest", len(expected))
+
+	ui.Section("Load images")
+	cmd := exec.Command("docker", "load", "--quiet", "-i", filepath.Join(work, imageBundleImagesName))
+	cmd.Env = os.Environ()
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("docker load failed: %s", blankDefault(strings.TrimSpace(string(out)), err.Error()))
+	}
+	rows := make([][]string, 0, len(manifest.Images))
+	mismatched := 0
+	for _, image := range manifest.Images {
+		id := dockerImageID(image.Ref)
+		if strings.Contains(image.Ref, "@") {
+			// docker load does not restore digest references; look the image up by ID.
+			id = dockerImageID(image.ID)
+		}
+		state := ui.Green("verified")
+		if id != image.ID {
+			mismatched++
+			state = ui.Red("id mismatch")
+			if id == "" {
+				state = ui.Red("missing")
+			}
+		}
+		rows = append(rows, []string{image.Ref, "sha256:" + shortImageID(image.ID), state})
+	}
+	ui.Table([]string{"IMAGE", "ID", "STATE"}, rows)
+	if mismatched > 0 {
+		return fmt.Errorf("%d image(s) do not match the bundle manifest", mismatched)
+	}
+	ui.Success("Loaded %d image(s)", len(manifest.Images))
+
+	if len(manifest.Models) == 0 {
+		return nil
+	}
+	ui.Section("Load models")
+	container, err := ollamaContainer(opts.Project)
+	if err != nil {
+		return fmt.Errorf("%w; start it with hbctl start --element ollama --enterprise and load the bundle again to add the model weights", err)
+	}
+	cmd = exec.Command("docker", "cp", filepath.Join(work, "models")+string(os.PathSeparator)+".", container+":"+ollamaModelsDir(container))
+	cmd.Env = os.Environ()
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to copy model weights into ollama: %s", blankDefault(strings.TrimSpace(string(out)), err.Error()))
+	}
+	for _, model := range manifest.Models {
+		ui.Success("Model ready: %s", model.Name)
+	}
+	return nil
+}
+
+func writeImageBundle(bundle string, work string, manifest imageBundleManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	partial := bundle + ".partial"
+	file, err := os.Create(partial)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(partial)
+
+	encoder, err := zstd.NewWriter(file)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	writer := tar.NewWriter(encoder)
+	write := func() error {
+		header := &tar.Header{Name: imageBundleManifestName, Mode: 0o644, Size: int64(len(data)), ModTime: manifest.Created}
+		if err := writer.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := writer.Write(data); err != nil {
+			return err
+		}
+		for _, entry := range manifest.Files {
+			source, err := os.Open(filepath.Join(work, filepath.FromSlash(entry.Name)))
+			if err != nil {
+				return err
+			}
+			header := &tar.Header{Name: entry.Name, Mode: 0o644, Size: entry.Size, ModTime: manifest.Created}
+			if err := writer.WriteHeader(header); err != nil {
+				_ = source.Close()
+				return err
+			}
+			_, err = io.Copy(writer, source)
+			_ = source.Close()
+			if err != nil {
+				return err
+			}
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+		return encoder.Close()
+	}
+	if err := write(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(partial, bundle)
+}
+
+func extractBundleFile(reader io.Reader, dst string) (int64, string, error) {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return 0, "", err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, "", err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, hash), reader)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func fileSizeSHA256(path string) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// validBundleName accepts relative, clean, slash-separated paths only, so a
+// crafted manifest cannot write outside the extraction directory.
+func validBundleName(name string) bool {
+	return name != "" && name != imageBundleManifestName && path.Clean(name) == name &&
+		!path.IsAbs(name) && name != ".." && !strings.HasPrefix(name, "../") && !strings.Contains(name, `\`)
+}
+
+// ollamaImageRefs maps the ollama element's images to its services.
+func ollamaImageRefs(project string) (map[string][]string, error) {
+	composeArgs := ComposeFilesForElements([]string{"ollama"})
+	env, err := mongoLifecycleEnv(true)
+	if err != nil {
+		env = blankLifecycleEnv(true)
+	}
+	doc, err := composeConfigJSON(env, append([]string{"-p", project}, composeArgs...))
+	if err != nil {
+		return nil, err
+	}
+	refs := map[string][]string{}
+	for service, def := range doc.Services {
+		if CanonicalElementName(service) != "ollama" {
+			continue
+		}
+		if image := strings.TrimSpace(def.Image); image != "" {
+			refs[image] = append(refs[image], service)
+		}
+	}
+	if len(refs) == 0 {
+		return nil, fmt.Errorf("no ollama image found; is %s present?", ComposeOllama)
+	}
+	return refs, nil
+}
+
+// saveOllamaModel copies a model's manifest and blobs out of the running
+// ollama container into work/models, laid out as in the ollama models
+// directory, and checks each blob against its digest.
+func saveOllamaModel(project string, model string, work string) (bundledModel, error) {
+	container, err := ollamaContainer(project)
+	if err != nil {
+		return bundledModel{}, fmt.Errorf("%w; the model weights are copied from the ollama container", err)
+	}
+	modelsDir := ollamaModelsDir(container)
+	manifestPath := ollamaManifestPath(model)
+
+	cmd := exec.Command("docker", "exec", container, "cat", path.Join(modelsDir, manifestPath))
+	cmd.Env = os.Environ()
+	data, err := cmd.Output()
+	if err != nil {
+		return bundledModel{}, fmt.Errorf("model %s is not pulled in ollama; run hbctl model pull %s first", model, model)
+	}
+	var parsed ollamaManifest
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return bundledModel{}, fmt.Errorf("failed to parse the ollama manifest of %s: %w", model, err)
+	}
+
+	saved := bundledModel{Name: model, Manifest: path.Join("models", manifestPath)}
+	dst := filepath.Join(work, filepath.FromSlash(saved.Manifest))
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return bundledModel{}, err
+	}
+	if err := os.WriteFile(dst, data, 0o644); err != nil {
+		return bundledModel{}, err
+	}
+	if err := os.MkdirAll(filepath.Join(work, "models", "blobs"), 0o755); err != nil {
+		return bundledModel{}, err
+	}
+
+	for _, layer := range append([]ollamaLayer{parsed.Config}, parsed.Layers...) {
+		algorithm, sum, ok := strings.Cut(layer.Digest, ":")
+		if !ok || algorithm != "sha256" {
+			return bundledModel{}, fmt.Errorf("model %s has an unsupported blob digest %q", model, layer.Digest)
+		}
+		blob := path.Join("models", "blobs", "sha256-"+sum)
+		cmd := exec.Command("docker", "cp", container+":"+path.Join(modelsDir, "blobs", "sha256-"+sum), filepath.Join(work, filepath.FromSlash(blob)))
+		cmd.Env = os.Environ()
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return bundledModel{}, fmt.Errorf("failed to copy %s blob %s: %s", model, shortImageID(sum), blankDefault(strings.TrimSpace(string(out)), err.Error()))
+		}
+		_, got, err := fileSizeSHA256(filepath.Join(work, filepath.FromSlash(blob)))
+		if err != nil {
+			return bundledModel{}, err
+		}
+		if got != sum {
+			return bundledModel{}, fmt.Errorf("%s blob %s is corrupt in ollama (sha256 %s)", model, shortImageID(sum), got)
+		}
+		saved.Blobs = append(saved.Blobs, blob)
+	}
+	return saved, nil
+}
+
+// ollamaManifestPath returns where ollama keeps a model's manifest, relative to
+// its models directory: qwen2.5-coder:7b is
+// manifests/registry.ollama.ai/library/qwen2.5-coder/7b.
+func ollamaManifestPath(model string) string {
+	name, tag := strings.TrimSpace(model), "latest"
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, tag = name[:i], name[i+1:]
+	}
+	parts := strings.Split(name, "/")
+	switch len(parts) {
+	case 1:
+		parts = []string{ollamaDefaultRegistry, "library", parts[0]}
+	case 2:
+		parts = append([]string{ollamaDefaultRegistry}, parts...)
+	}
+	return path.Join(append(append([]string{"manifests"}, parts...), tag)...)
+}
+
+func ollamaContainer(project string) (string, error) {
+	cmd := exec.Command("docker", "ps", "--filter", "label=com.docker.compose.project="+project, "--filter", "label=com.docker.compose.service=ollama", "--format", "{{.ID}}")
+	cmd.Env = os.Environ()
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	id := strings.TrimSpace(strings.Split(string(out), "\n")[0])
+	if id == "" {
+		return "", fmt.Errorf("ollama container is not running")
+	}
+	return id, nil
+}
+
+func ollamaModelsDir(container string) string {
+	cmd := exec.Command("docker", "exec", container, "printenv", "OLLAMA_MODELS")
+	cmd.Env = os.Environ()
+	out, err := cmd.Output()
+	if err != nil {
+		return ollamaDefaultModelsDir
+	}
+	return blankDefault(strings.TrimSpace(string(out)), ollamaDefaultModelsDir)
+}
+
+func unionServices(a []string, b []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, service := range append(append([]string{}, a...), b...) {
+		if !seen[service] {
+			seen[service] = true
+			out = append(out, service)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,544 @@
+package local
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/herringbonedev/hbctl/internal/ui"
+	"github.com/herringbonedev/hbctl/internal/units"
+)
+
+// DefaultImagePullParallel is how many images hbctl images pull fetches at
+// once.
+const DefaultImagePullParallel = 4
+
+type ImagesOptions struct {
+	Project    string
+	Unit       string
+	Enterprise bool
+	Parallel   int
+	DryRun     bool
+	// ArchiveRoot, when set, makes prune keep images referenced by the
+	// compose files in each snapshot under it.
+	ArchiveRoot string
+	// Bundle is the image bundle save writes and load reads.
+	Bundle string
+	// Models adds the ollama image and the fingerprint tuner model weights to
+	// a saved bundle.
+	Models bool
+}
+
+type dockerImage struct {
+	Repository string `json:"Repository"`
+	Tag        string `json:"Tag"`
+	Digest     string `json:"Digest"`
+	ID         string `json:"ID"`
+	Size       string `json:"Size"`
+	Created    string `json:"CreatedSince"`
+}
+
+// ref returns the image as repo:tag, or its ID when it is untagged.
+func (image dockerImage) ref() string {
+	if image.Repository == "" || image.Repository == "<none>" || image.Tag == "" || image.Tag == "<none>" {
+		return image.ID
+	}
+	return image.Repository + ":" + image.Tag
+}
+
+// ListImages shows the images the current compose files reference, followed
+// by other tags of the same repositories that nothing references any more.
+func ListImages(opts ImagesOptions) error {
+	ui.Header("Herringbone images")
+
+	referenced, err := composeImageRefs(opts.Project, opts.Unit, opts.Enterprise)
+	if err != nil {
+		return err
+	}
+	images, err := listDockerImages()
+	if err != nil {
+		return err
+	}
+	usedBy, err := runningImageElements(opts.Project)
+	if err != nil {
+		return err
+	}
+	repos := imageRepositories(referenced)
+
+	byRef := map[string]dockerImage{}
+	for _, image := range images {
+		byRef[image.ref()] = image
+		if image.Digest != "" && image.Digest != "<none>" {
+			byRef[image.Repository+"@"+image.Digest] = image
+		}
+	}
+
+	rows := [][]string{}
+	refs := make([]string, 0, len(referenced))
+	for ref := range referenced {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	shown := map[string]bool{}
+	for _, ref := range refs {
+		repo, tag := splitImageRef(ref)
+		image, ok := byRef[normalizeImageRef(ref)]
+		if !ok {
+			rows = append(rows, []string{repo, tag, ui.Dim("not pulled"), "-", "-", strings.Join(referenced[ref], ", ")})
+			continue
+		}
+		shown[image.ref()] = true
+		rows = append(rows, []string{repo, tag, shortDigest(image.Digest), image.Size, blankDefault(strings.Join(usedBy[image.ID], ", "), "-"), strings.Join(referenced[ref], ", ")})
+	}
+
+	unused := 0
+	for _, image := range images {
+		if shown[image.ref()] || !isHerringboneImage(image, repos) {
+			continue
+		}
+		unused++
+		shown[image.ref()] = true
+		rows = append(rows, []string{image.Repository, image.Tag, shortDigest(image.Digest), image.Size, blankDefault(strings.Join(usedBy[image.ID], ", "), "-"), ui.Yellow("unreferenced")})
+	}
+
+	ui.KeyValues([][2]string{{"referenced", fmt.Sprintf("%d", len(refs))}, {"unreferenced", fmt.Sprintf("%d", unused)}})
+	if len(rows) == 0 {
+		ui.Skip("No Herringbone images found")
+		return nil
+	}
+	ui.Table([]string{"IMAGE", "TAG", "DIGEST", "SIZE", "IN USE BY", "COMPOSE SERVICES"}, rows)
+	if unused > 0 {
+		ui.Info("Remove unreferenced images with hbctl images prune")
+	}
+	return nil
+}
+
+// PullImages pulls every image the unit or full stack references, up to
+// Parallel at a time, without touching running containers.
+func PullImages(opts ImagesOptions) error {
+	ui.Header("Herringbone image pull")
+
+	referenced, err := composeImageRefs(opts.Project, opts.Unit, opts.Enterprise)
+	if err != nil {
+		return err
+	}
+	refs := make([]string, 0, len(referenced))
+	for ref := range referenced {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	if len(refs) == 0 {
+		ui.Skip("No images referenced by the compose files")
+		return nil
+	}
+
+	parallel := opts.Parallel
+	if parallel <= 0 {
+		parallel = DefaultImagePullParallel
+	}
+	ui.KeyValues([][2]string{{"images", fmt.Sprintf("%d", len(refs))}, {"parallel", fmt.Sprintf("%d", parallel)}})
+	if opts.DryRun {
+		for _, ref := range refs {
+			ui.Command("docker pull %s", ref)
+		}
+		return nil
+	}
+
+	type pullResult struct {
+		ref      string
+		output   string
+		err      error
+		duration time.Duration
+	}
+	jobs := make(chan string)
+	results := make(chan pullResult)
+	for i := 0; i < parallel; i++ {
+		go func() {
+			for ref := range jobs {
+				started := time.Now()
+				cmd := exec.Command("docker", "pull", "--quiet", ref)
+				cmd.Env = os.Environ()
+				out, err := cmd.CombinedOutput()
+				results <- pullResult{ref: ref, output: strings.TrimSpace(string(out)), err: err, duration: time.Since(started)}
+			}
+		}()
+	}
+	go func() {
+		for _, ref := range refs {
+			jobs <- ref
+		}
+		close(jobs)
+	}()
+
+	byRef := map[string]pullResult{}
+	failed := 0
+	for range refs {
+		result := <-results
+		byRef[result.ref] = result
+		if result.err != nil {
+			failed++
+			ui.Error("%s: %s", result.ref, blankDefault(result.output, result.err.Error()))
+			continue
+		}
+		ui.Success("Pulled %s", result.ref)
+	}
+
+	rows := make([][]string, 0, len(refs))
+	for _, ref := range refs {
+		result := byRef[ref]
+		state := ui.Green("pulled")
+		if result.err != nil {
+			state = ui.Red("failed")
+		}
+		rows = append(rows, []string{ref, state, result.duration.Round(100 * time.Millisecond).String()})
+	}
+	ui.Section("Pull summary")
+	ui.Table([]string{"IMAGE", "RESULT", "DURATION"}, rows)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d image(s) failed to pull", failed, len(refs))
+	}
+	return nil
+}
+
+// PruneImages removes Herringbone images that no container uses and the
+// current compose files, enterprise services included, do not reference. With
+// ArchiveRoot set, images the archived compose directories reference are kept
+// too, so a rollback does not have to pull them again.
+func PruneImages(opts ImagesOptions) error {
+	ui.Header("Herringbone image prune")
+
+	referenced, err := composeImageRefs(opts.Project, "", true)
+	if err != nil {
+		return err
+	}
+	keep := map[string]string{}
+	for ref := range referenced {
+		keep[normalizeImageRef(ref)] = "current compose files"
+	}
+	if opts.ArchiveRoot != "" {
+		archived, err := archivedComposeImageRefs(opts.ArchiveRoot)
+		if err != nil {
+			return err
+		}
+		for ref, archive := range archived {
+			if _, ok := keep[normalizeImageRef(ref)]; !ok {
+				keep[normalizeImageRef(ref)] = "archive " + archive
+			}
+		}
+	}
+
+	images, err := listDockerImages()
+	if err != nil {
+		return err
+	}
+	inUse, err := containerImageIDs()
+	if err != nil {
+		return err
+	}
+	candidates := []dockerImage{}
+	kept := 0
+	prefixes := herringboneImagePrefixes()
+	for _, image := range images {
+		// Third-party repositories the stack uses, such as mongo or nginx,
+		// may hold tags other projects need; only Herringbone's own are pruned.
+		if !isHerringboneRepository(image.Repository, prefixes) {
+			continue
+		}
+		reason, ok := keep[image.ref()]
+		if !ok && image.Digest != "" && image.Digest != "<none>" {
+			reason, ok = keep[image.Repository+"@"+image.Digest]
+		}
+		if ok {
+			if strings.HasPrefix(reason, "archive ") {
+				ui.Skip("%s: referenced by %s", image.ref(), reason)
+			}
+			kept++
+			continue
+		}
+		if inUse[image.ID] {
+			ui.Skip("%s: used by a container", image.ref())
+			kept++
+			continue
+		}
+		candidates = append(candidates, image)
+	}
+
+	policy := []string{
+		"Only Herringbone repositories are considered: names containing herringbone, or starting with a prefix in HBCTL_IMAGE_PREFIX.",
+		"Images referenced by the current compose files are kept.",
+		"Images used by any container, running or stopped, are kept.",
+	}
+	if opts.ArchiveRoot != "" {
+		policy = append(policy, "Images referenced by compose files in "+opts.ArchiveRoot+" are kept.")
+	}
+	ui.Plan("Image prune policy", policy)
+	ui.KeyValues([][2]string{{"kept", fmt.Sprintf("%d", kept)}, {"removable", fmt.Sprintf("%d", len(candidates))}})
+	if len(candidates) == 0 {
+		ui.Success("Nothing to prune")
+		return nil
+	}
+
+	removed := 0
+	for _, image := range candidates {
+		if opts.DryRun {
+			ui.Command("docker image rm %s  # %s", image.ref(), image.Size)
+			continue
+		}
+		cmd := exec.Command("docker", "image", "rm", image.ref())
+		cmd.Env = os.Environ()
+		if out, err := cmd.CombinedOutput(); err != nil {
+			ui.Warn("%s: %s", image.ref(), strings.TrimSpace(string(out)))
+			continue
+		}
+		removed++
+		ui.Success("Removed %s (%s)", image.ref(), image.Size)
+	}
+	if opts.DryRun {
+		ui.Info("Dry run: %d image(s) would be removed", len(candidates))
+		return nil
+	}
+	ui.Success("Removed %d of %d image(s)", removed, len(candidates))
+	return nil
+}
+
+// composeImageRefs maps each image the unit's (or full stack's) compose files
+// reference to the services that use it.
+func composeImageRefs(project string, unit string, enterprise bool) (map[string][]string, error) {
+	composeArgs := ComposeFilesForFullStack(enterprise)
+	if strings.TrimSpace(unit) != "" {
+		elements := units.UnitElements[strings.TrimSpace(unit)]
+		if len(elements) == 0 {
+			return nil, fmt.Errorf("unknown unit: %s", unit)
+		}
+		composeArgs = ComposeFilesForElements(filterEnterpriseElements(elements, enterprise))
+	}
+	if len(composeArgs) == 0 {
+		return nil, fmt.Errorf("no compose files found in the current directory")
+	}
+
+	env, err := mongoLifecycleEnv(enterprise)
+	if err != nil {
+		env = blankLifecycleEnv(enterprise)
+	}
+	doc, err := composeConfigJSON(env, append([]string{"-p", project}, composeArgs...))
+	if err != nil {
+		return nil, err
+	}
+	refs := map[string][]string{}
+	for service, def := range doc.Services {
+		if image := strings.TrimSpace(def.Image); image != "" {
+			refs[image] = append(refs[image], service)
+		}
+	}
+	for ref := range refs {
+		sort.Strings(refs[ref])
+	}
+	return refs, nil
+}
+
+// archivedComposeImageRefs maps each image referenced by an archived compose
+// directory to the archive name. An archive that cannot be read stops the
+// prune rather than risk removing an image it needs.
+func archivedComposeImageRefs(root string) (map[string]string, error) {
+	entries, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	env, err := mongoLifecycleEnv(true)
+	if err != nil {
+		env = blankLifecycleEnv(true)
+	}
+
+	refs := map[string]string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		files := []string{}
+		for _, pattern := range []string{"compose*.yml", "compose*.yaml", "docker-compose*.yml", "docker-compose*.yaml"} {
+			matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+			files = append(files, matches...)
+		}
+		if len(files) == 0 {
+			continue
+		}
+		sort.Strings(files)
+		args := []string{"compose", "--project-directory", dir}
+		for _, file := range files {
+			args = append(args, "-f", file)
+		}
+		args = append(args, "config", "--images")
+		cmd := exec.Command("docker", args...)
+		cmd.Env = composeConfigEnv()
+		for k, v := range env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read images from archive %s: %s", entry.Name(), blankDefault(strings.TrimSpace(stderr.String()), err.Error()))
+		}
+		for _, line := range strings.Split(string(out), "\n") {
+			if ref := strings.TrimSpace(line); ref != "" {
+				if _, ok := refs[ref]; !ok {
+					refs[ref] = entry.Name()
+				}
+			}
+		}
+	}
+	return refs, nil
+}
+
+func listDockerImages() ([]dockerImage, error) {
+	cmd := exec.Command("docker", "image", "ls", "--digests", "--no-trunc", "--format", "{{json .}}")
+	cmd.Env = os.Environ()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("docker image ls failed: %s", blankDefault(strings.TrimSpace(stderr.String()), err.Error()))
+	}
+	images := []dockerImage{}
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var image dockerImage
+		if err := json.Unmarshal([]byte(line), &image); err != nil {
+			return nil, fmt.Errorf("failed to parse docker image ls output: %w", err)
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+// runningImageElements maps image IDs to the elements whose running
+// containers use them.
+func runningImageElements(project string) (map[string][]string, error) {
+	containers, err := listHerringboneContainers(project, false)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string][]string{}
+	seen := map[string]bool{}
+	for _, container := range containers {
+		id := strings.TrimSpace(dockerInspectFormat(blankDefault(container.ID, container.Name), "{{.Image}}"))
+		element := CanonicalElementName(container.Service)
+		if id == "" || seen[id+"/"+element] {
+			continue
+		}
+		seen[id+"/"+element] = true
+		out[id] = append(out[id], element)
+	}
+	for id := range out {
+		sort.Strings(out[id])
+	}
+	return out, nil
+}
+
+// containerImageIDs returns the image IDs of every container on the host,
+// running or stopped.
+func containerImageIDs() (map[string]bool, error) {
+	cmd := exec.Command("docker", "ps", "--all", "--quiet", "--no-trunc")
+	cmd.Env = os.Environ()
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("docker ps failed: %w", err)
+	}
+	ids := strings.Fields(string(out))
+	images := map[string]bool{}
+	if len(ids) == 0 {
+		return images, nil
+	}
+	inspect := exec.Command("docker", append([]string{"inspect", "--format", "{{.Image}}"}, ids...)...)
+	inspect.Env = os.Environ()
+	out, err = inspect.Output()
+	if err != nil {
+		return nil, fmt.Errorf("docker inspect failed: %w", err)
+	}
+	for _, id := range strings.Fields(string(out)) {
+		images[id] = true
+	}
+	return images, nil
+}
+
+// isHerringboneImage reports whether an image belongs to a repository the
+// compose files use or is named after Herringbone.
+func isHerringboneImage(image dockerImage, repos map[string]bool) bool {
+	return repos[image.Repository] || strings.Contains(strings.ToLower(image.Repository), "herringbone")
+}
+
+// isHerringboneRepository reports whether repo is one of Herringbone's own
+// image repositories rather than a third-party image the stack uses.
+func isHerringboneRepository(repo string, prefixes []string) bool {
+	if strings.Contains(strings.ToLower(repo), "herringbone") {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(repo, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// herringboneImagePrefixes returns the comma-separated repository prefixes in
+// HBCTL_IMAGE_PREFIX, for registries whose image names do not say herringbone.
+func herringboneImagePrefixes() []string {
+	prefixes := []string{}
+	for _, prefix := range strings.Split(os.Getenv("HBCTL_IMAGE_PREFIX"), ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+func imageRepositories(refs map[string][]string) map[string]bool {
+	repos := map[string]bool{}
+	for ref := range refs {
+		repo, _ := splitImageRef(ref)
+		repos[repo] = true
+	}
+	return repos
+}
+
+// splitImageRef splits repo:tag, leaving a registry port in the repository
+// and defaulting the tag to latest. Digest references keep the digest as the
+// tag.
+func splitImageRef(ref string) (string, string) {
+	if repo, digest, ok := strings.Cut(ref, "@"); ok {
+		return repo, digest
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, "latest"
+}
+
+func normalizeImageRef(ref string) string {
+	repo, tag := splitImageRef(ref)
+	if strings.Contains(ref, "@") {
+		return repo + "@" + tag
+	}
+	return repo + ":" + tag
+}
+
+func shortDigest(digest string) string {
+	if digest == "" || digest == "<none>" {
+		return "-"
+	}
+	return "sha256:" + shortImageID(digest)
+}
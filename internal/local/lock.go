@@ -0,0 +1,557 @@
+package local
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/herringbonedev/hbctl/internal/ui"
+	"gopkg.in/yaml.v3"
+)
+
+// LockFileName pins the image digests of the compose directory hbctl runs in.
+// It is meant to be committed next to the compose files.
+const LockFileName = "hbctl.lock"
+
+// LockFileVersion is the newest lock file format this hbctl understands.
+const LockFileVersion = 1
+
+// lockOverrideDir holds the compose overrides generated from the lock file,
+// one per compose file, that swap each pinned service's image for its digest.
+var lockOverrideDir = filepath.Join(".hbctl", "lock")
+
+type LockOptions struct {
+	Project    string
+	Enterprise bool
+	Element    string
+	Update     bool
+	Pull       bool
+}
+
+type lockFile struct {
+	Version   int                    `json:"version"`
+	Generated time.Time              `json:"generated"`
+	Services  map[string]lockedImage `json:"services"`
+}
+
+// lockedImage records where a pin came from. Compose is the image exactly as
+// written in File and Image is the reference compose resolved it to; when any
+// of them changes, for example because .env sets a new ${TAG}, the pin is
+// stale and no longer applies.
+type lockedImage struct {
+	File    string `json:"file"`
+	Compose string `json:"compose"`
+	Image   string `json:"image"`
+	Digest  string `json:"digest"`
+}
+
+var lockWarning sync.Once
+
+// staleLockWarned remembers the services already warned about, since the
+// overrides are regenerated for every compose call.
+var (
+	staleLockMu     sync.Mutex
+	staleLockWarned = map[string]bool{}
+)
+
+// Lock resolves the images of the active compose files to registry digests
+// and writes them to hbctl.lock. Existing pins are kept unless Update is set;
+// Element limits the run to that element's services.
+func Lock(opts LockOptions) error {
+	ui.Header("Herringbone lock")
+
+	lock, err := loadLockFile()
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		lock = &lockFile{Services: map[string]lockedImage{}}
+	}
+
+	composeArgs := stripLockOverrides(ComposeFilesForFullStack(opts.Enterprise))
+	if strings.TrimSpace(opts.Element) != "" {
+		element := CanonicalElementName(opts.Element)
+		if IsEnterpriseElement(element) && !opts.Enterprise {
+			return fmt.Errorf("%s is an enterprise service; pass --enterprise to lock it", element)
+		}
+		composeArgs = stripLockOverrides(ComposeFilesForElements([]string{element}))
+	}
+	files, err := composeFileArgs(composeArgs)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no compose files found in the current directory")
+	}
+
+	targets := map[string]bool{}
+	if strings.TrimSpace(opts.Element) != "" {
+		service, err := resolveComposeServiceName(composeArgs, CanonicalElementName(opts.Element))
+		if err != nil {
+			return err
+		}
+		targets[service] = true
+	}
+
+	env, err := mongoLifecycleEnv(opts.Enterprise)
+	if err != nil {
+		env = blankLifecycleEnv(opts.Enterprise)
+	}
+	doc, err := composeConfigJSON(env, append([]string{"-p", opts.Project}, composeArgs...))
+	if err != nil {
+		return err
+	}
+
+	// The file that sets a service's image last is the one whose image compose
+	// uses, so that is where the pin is recorded.
+	sources := map[string]lockedImage{}
+	for _, file := range files {
+		images, err := composeFileImages(file)
+		if err != nil {
+			return err
+		}
+		for service, image := range images {
+			sources[service] = lockedImage{File: file, Compose: image}
+		}
+	}
+
+	services := make([]string, 0, len(sources))
+	for service := range sources {
+		if len(targets) == 0 || targets[service] {
+			services = append(services, service)
+		}
+	}
+	sort.Strings(services)
+	ui.KeyValues([][2]string{{"file", LockFileName}, {"services", fmt.Sprintf("%d", len(services))}, {"update", ui.Bool(opts.Update)}})
+
+	rows := [][]string{}
+	changed := 0
+	for _, service := range services {
+		source := sources[service]
+		source.Image = strings.TrimSpace(doc.Services[service].Image)
+		if source.Image == "" {
+			continue
+		}
+		existing, ok := lock.Services[service]
+		if ok && !opts.Update && existing.File == source.File && existing.Compose == source.Compose && existing.Image == source.Image {
+			rows = append(rows, []string{service, source.Image, shortDigestRef(existing.Digest), "unchanged"})
+			continue
+		}
+
+		digest, err := resolveImageDigest(source.Image, opts.Pull)
+		if err != nil {
+			ui.Warn("%s: %v", service, err)
+			rows = append(rows, []string{service, source.Image, "-", ui.Red("not pinned")})
+			continue
+		}
+		source.Digest = digest
+		state := "pinned"
+		switch {
+		case ok && existing.Digest == digest:
+			state = "unchanged"
+		case ok:
+			state = ui.Yellow("updated")
+		default:
+			state = ui.Green(state)
+		}
+		if !ok || existing != source {
+			changed++
+		}
+		lock.Services[service] = source
+		rows = append(rows, []string{service, source.Image, shortDigestRef(digest), state})
+	}
+	ui.Table([]string{"SERVICE", "IMAGE", "DIGEST", "STATE"}, rows)
+
+	if changed == 0 {
+		ui.Success("%s is up to date", LockFileName)
+		return nil
+	}
+	lock.Version = LockFileVersion
+	lock.Generated = time.Now().UTC().Truncate(time.Second)
+	if err := saveLockFile(lock); err != nil {
+		return err
+	}
+	ui.Success("Wrote %d pin(s) to %s", changed, LockFileName)
+	ui.Info("start, restart, and upgrade now run the pinned digests; refresh them with hbctl lock --update")
+	return nil
+}
+
+// VerifyLock reports running containers whose image is not the digest pinned
+// for their service, and pins that no longer match the compose files.
+func VerifyLock(project string) error {
+	ui.Header("Herringbone lock verify")
+
+	lock, err := loadLockFile()
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		return fmt.Errorf("%s not found; run hbctl lock first", LockFileName)
+	}
+
+	fileArgs := []string{}
+	seenFile := map[string]bool{}
+	for _, pin := range lock.Services {
+		if !seenFile[pin.File] {
+			seenFile[pin.File] = true
+			fileArgs = append(fileArgs, "-f", pin.File)
+		}
+	}
+	resolved, resolveErr := interpolatedLockImages(lock, fileArgs)
+	if resolveErr != nil {
+		ui.Warn("Could not resolve interpolated images: %v", resolveErr)
+	}
+	stale := []string{}
+	for service, pin := range lock.Services {
+		images, err := composeFileImages(pin.File)
+		if err != nil || images[service] != pin.Compose || !pinMatchesResolved(pin, service, resolved) {
+			stale = append(stale, service)
+		}
+	}
+	sort.Strings(stale)
+
+	containers, err := listHerringboneContainers(project, false)
+	if err != nil {
+		return err
+	}
+	rows := [][]string{}
+	unpinned := 0
+	for _, container := range containers {
+		if strings.Contains(container.Project, "-receiver-") {
+			continue
+		}
+		service := blankDefault(container.RawService, container.Service)
+		pin, ok := lock.Services[service]
+		imageID := strings.TrimSpace(dockerInspectFormat(blankDefault(container.ID, container.Name), "{{.Image}}"))
+		digests := imageRepoDigests(imageID)
+		running := "-"
+		if len(digests) > 0 {
+			running = shortDigestRef(digests[0])
+		}
+		switch {
+		case !ok:
+			rows = append(rows, []string{service, container.Name, "-", running, ui.Yellow("not locked")})
+		case hasImageDigest(digests, pin.Digest):
+			rows = append(rows, []string{service, container.Name, shortDigestRef(pin.Digest), shortDigestRef(pin.Digest), ui.Green("pinned")})
+		default:
+			unpinned++
+			rows = append(rows, []string{service, container.Name, shortDigestRef(pin.Digest), running, ui.Red("unpinned")})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i][0]+rows[i][1] < rows[j][0]+rows[j][1] })
+
+	ui.KeyValues([][2]string{{"file", LockFileName}, {"pins", fmt.Sprintf("%d", len(lock.Services))}, {"containers", fmt.Sprintf("%d", len(rows))}})
+	if len(rows) > 0 {
+		ui.Table([]string{"SERVICE", "CONTAINER", "PINNED", "RUNNING", "STATE"}, rows)
+	} else {
+		ui.Skip("No running containers")
+	}
+	for _, service := range stale {
+		ui.Warn("%s: the compose image changed since it was pinned; run hbctl lock --update --element %s", service, CanonicalElementName(service))
+	}
+	if unpinned > 0 {
+		ui.Info("Recreate unpinned elements with hbctl upgrade --element <name> to run the pinned digests")
+		return fmt.Errorf("%d container(s) run an unpinned image", unpinned)
+	}
+	ui.Success("Every locked container runs its pinned digest")
+	return nil
+}
+
+// withLockOverrides adds the generated lock override after each compose file
+// that has pinned services. Without a lock file the arguments are returned
+// unchanged.
+func withLockOverrides(args []string) []string {
+	lock, err := loadLockFile()
+	if err != nil {
+		lockWarning.Do(func() { ui.Warn("Ignoring %s: %v", LockFileName, err) })
+		return args
+	}
+	if lock == nil || len(lock.Services) == 0 {
+		return args
+	}
+
+	resolved, err := interpolatedLockImages(lock, stripLockOverrides(args))
+	if err != nil {
+		lockWarning.Do(func() { ui.Warn("Not applying interpolated pins from %s: %v", LockFileName, err) })
+	}
+
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		out = append(out, args[i])
+		if args[i] != "-f" || i+1 >= len(args) {
+			continue
+		}
+		file := args[i+1]
+		out = append(out, file)
+		i++
+		if isLockOverrideFile(file) {
+			continue
+		}
+		override, err := writeLockOverride(lock, file, resolved)
+		if err != nil {
+			lockWarning.Do(func() { ui.Warn("Ignoring %s: %v", LockFileName, err) })
+			continue
+		}
+		if override != "" {
+			out = append(out, "-f", override)
+		}
+	}
+	return out
+}
+
+// writeLockOverride writes the override pinning the services file defines and
+// returns its path, or "" when none of them has a current pin. resolved holds
+// the interpolated images of services whose compose image uses variables.
+func writeLockOverride(lock *lockFile, file string, resolved map[string]string) (string, error) {
+	path := filepath.Join(lockOverrideDir, strings.NewReplacer("/", "_", "\\", "_").Replace(filepath.Clean(file)))
+	images, err := composeFileImages(file)
+	if err != nil {
+		return "", err
+	}
+	pinned := map[string]map[string]string{}
+	for service, image := range images {
+		pin, ok := lock.Services[service]
+		if !ok || pin.File != file || pin.Compose != image || pin.Digest == "" {
+			continue
+		}
+		if !pinMatchesResolved(pin, service, resolved) {
+			warnStalePin(service, pin, resolved[service])
+			continue
+		}
+		pinned[service] = map[string]string{"image": pin.Digest}
+	}
+	if len(pinned) == 0 {
+		_ = os.Remove(path)
+		return "", nil
+	}
+
+	data, err := yaml.Marshal(map[string]any{"services": pinned})
+	if err != nil {
+		return "", err
+	}
+	data = append([]byte("# Generated by hbctl from "+LockFileName+"; do not edit.\n"), data...)
+	if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		return path, nil
+	}
+	if err := os.MkdirAll(lockOverrideDir, 0o755); err != nil {
+		return "", err
+	}
+	// Compose may be reading the previous override; replace it in one step.
+	tmp, err := os.CreateTemp(lockOverrideDir, ".override-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	return path, nil
+}
+
+// lockedComposeArgs returns each element's compose arguments with lock
+// overrides applied. Parallel starts call it before any worker runs, so the
+// overrides are written once and lock warnings print outside element output.
+func lockedComposeArgs(elements []string) map[string][]string {
+	out := make(map[string][]string, len(elements))
+	for _, element := range elements {
+		element = CanonicalElementName(element)
+		out[element] = withLockOverrides(ComposeFilesForElement(element))
+	}
+	return out
+}
+
+// interpolatedLockImages returns the images compose resolves for pinned
+// services whose compose image uses variables, or nil when none does.
+func interpolatedLockImages(lock *lockFile, composeArgs []string) (map[string]string, error) {
+	needed := false
+	for _, pin := range lock.Services {
+		if strings.Contains(pin.Compose, "$") {
+			needed = true
+			break
+		}
+	}
+	if !needed || len(composeArgs) == 0 {
+		return nil, nil
+	}
+	doc, err := composeConfigJSON(nil, composeArgs)
+	if err != nil {
+		return nil, err
+	}
+	resolved := map[string]string{}
+	for service, config := range doc.Services {
+		resolved[service] = strings.TrimSpace(config.Image)
+	}
+	return resolved, nil
+}
+
+// pinMatchesResolved reports whether a pin still names the image compose
+// resolves. Images without variables always resolve to what the file says.
+func pinMatchesResolved(pin lockedImage, service string, resolved map[string]string) bool {
+	if !strings.Contains(pin.Compose, "$") {
+		return true
+	}
+	image, ok := resolved[service]
+	return ok && image == pin.Image
+}
+
+func warnStalePin(service string, pin lockedImage, image string) {
+	staleLockMu.Lock()
+	defer staleLockMu.Unlock()
+	if staleLockWarned[service] {
+		return
+	}
+	staleLockWarned[service] = true
+	ui.Warn("%s: pinned for %s but compose now resolves %s; not pinning it, run hbctl lock --update --element %s", service, pin.Image, blankDefault(image, "an unknown image"), CanonicalElementName(service))
+}
+
+func isLockOverrideFile(file string) bool {
+	return filepath.Dir(filepath.Clean(file)) == lockOverrideDir
+}
+
+// stripLockOverrides removes generated lock overrides from compose arguments
+// so images resolve to the tags the compose files name.
+func stripLockOverrides(args []string) []string {
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-f" && i+1 < len(args) && isLockOverrideFile(args[i+1]) {
+			i++
+			continue
+		}
+		out = append(out, args[i])
+	}
+	return out
+}
+
+// composeFileImages returns the image each service sets in one compose file,
+// uninterpolated.
+func composeFileImages(file string) (map[string]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Services map[string]struct {
+			Image string `yaml:"image"`
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	images := map[string]string{}
+	for service, def := range doc.Services {
+		if image := strings.TrimSpace(def.Image); image != "" {
+			images[service] = image
+		}
+	}
+	return images, nil
+}
+
+// resolveImageDigest returns image as repo@sha256:..., pulling it first so
+// the digest is the one the registry serves for the tag now.
+func resolveImageDigest(image string, pull bool) (string, error) {
+	if strings.Contains(image, "@sha256:") {
+		return image, nil
+	}
+	if pull {
+		cmd := exec.Command("docker", "pull", "--quiet", image)
+		cmd.Env = os.Environ()
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return "", fmt.Errorf("docker pull %s failed: %s", image, blankDefault(strings.TrimSpace(string(out)), err.Error()))
+		}
+	}
+	digests := imageRepoDigests(image)
+	if len(digests) == 0 {
+		return "", fmt.Errorf("%s has no registry digest; locally built images cannot be pinned", image)
+	}
+	repo, _ := splitImageRef(image)
+	for _, digest := range digests {
+		digestRepo, _, _ := strings.Cut(digest, "@")
+		if digestRepo == repo || strings.TrimPrefix(digestRepo, "docker.io/library/") == repo || strings.TrimPrefix(digestRepo, "docker.io/") == repo {
+			return repo + "@" + strings.SplitN(digest, "@", 2)[1], nil
+		}
+	}
+	_, sha, _ := strings.Cut(digests[0], "@")
+	return repo + "@" + sha, nil
+}
+
+func imageRepoDigests(image string) []string {
+	if strings.TrimSpace(image) == "" {
+		return nil
+	}
+	cmd := exec.Command("docker", "image", "inspect", image, "--format", `{{join .RepoDigests " "}}`)
+	cmd.Env = os.Environ()
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(out))
+}
+
+func loadLockFile() (*lockFile, error) {
+	data, err := os.ReadFile(LockFileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lock lockFile
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("invalid lock file %s: %w", LockFileName, err)
+	}
+	if lock.Version > LockFileVersion {
+		return nil, fmt.Errorf("lock file %s uses version %d; this hbctl supports up to version %d", LockFileName, lock.Version, LockFileVersion)
+	}
+	if lock.Services == nil {
+		lock.Services = map[string]lockedImage{}
+	}
+	return &lock, nil
+}
+
+func saveLockFile(lock *lockFile) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(LockFileName, append(data, '\n'), 0o644)
+}
+
+func shortDigestRef(digest string) string {
+	if _, sha, ok := strings.Cut(digest, "@"); ok {
+		return "sha256:" + shortImageID(sha)
+	}
+	return blankDefault(digest, "-")
+}
+
+// hasImageDigest reports whether any repo digest carries the same sha256 as
+// pinned; registries may report the repository under another name.
+func hasImageDigest(digests []string, pinned string) bool {
+	_, want, _ := strings.Cut(pinned, "@")
+	for _, digest := range digests {
+		if _, sha, ok := strings.Cut(digest, "@"); ok && sha == want {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package local
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -48,3 +49,40 @@ func readDotEnvValue(key string) string {
 	}
 	return ""
 }
+
+// SaveFingerprintTunerModel writes the tuner model to ./.env so later runs
+// resolve it through ResolveFingerprintTunerModel.
+func SaveFingerprintTunerModel(model string) error {
+	path := filepath.Join(".", ".env")
+	content, _ := os.ReadFile(path)
+	lines := strings.Split(string(content), "\n")
+	wanted := map[string]string{
+		"FINGERPRINT_TUNER_LLM_MODEL": model,
+		"OLLAMA_MODEL":                model,
+	}
+	seen := map[string]bool{}
+	out := make([]string, 0, len(lines)+len(wanted))
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		replaced := false
+		for key, value := range wanted {
+			if strings.HasPrefix(trimmed, key+"=") {
+				if !seen[key] {
+					out = append(out, key+"="+value)
+					seen[key] = true
+				}
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			out = append(out, line)
+		}
+	}
+	for key, value := range wanted {
+		if !seen[key] {
+			out = append(out, key+"="+value)
+		}
+	}
+	return os.WriteFile(path, []byte(strings.TrimRight(strings.Join(out, "\n"), "\n")+"\n"), 0o644)
+}
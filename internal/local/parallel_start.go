@@ -0,0 +1,210 @@
+package local
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/herringbonedev/hbctl/internal/ui"
+)
+
+type elementStartResult struct {
+	element  string
+	err      error
+	output   bytes.Buffer
+	duration time.Duration
+}
+
+// startOrderedElements starts elements that are already in dependency order.
+// With parallel <= 1 they start one at a time exactly as before; otherwise
+// independent elements start concurrently on a pool of parallel workers.
+// Each element must pass its readiness probe before its dependents start.
+func startOrderedElements(project string, env map[string]string, graph *elementGraph, ordered []string, parallel int, readyTimeout time.Duration) error {
+	if parallel <= 1 || len(ordered) <= 1 {
+		for _, element := range ordered {
+			if err := startElement(project, env, element); err != nil {
+				return err
+			}
+			if err := waitElementReady(os.Stdout, project, env, element, readyTimeout); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return startElementsParallel(project, env, graph, ordered, parallel, readyTimeout)
+}
+
+// startElementsParallel runs startElementTo for each element once every
+// dependency in the list has started. Each element's output is buffered and
+// printed as one block when it finishes so compose output never interleaves.
+// The first failure stops new work from being scheduled; elements already
+// running are allowed to finish.
+func startElementsParallel(project string, env map[string]string, graph *elementGraph, ordered []string, parallel int, readyTimeout time.Duration) error {
+	inSet := map[string]bool{}
+	for _, element := range ordered {
+		inSet[element] = true
+	}
+
+	pending := map[string]int{}
+	dependents := map[string][]string{}
+	for _, element := range ordered {
+		for _, dep := range graph.deps[element] {
+			if !inSet[dep] {
+				continue
+			}
+			pending[element]++
+			dependents[dep] = append(dependents[dep], element)
+		}
+	}
+
+	// Shared prerequisites run once up front instead of once per worker.
+	if err := prepareParallelStart(project, env, ordered, inSet); err != nil {
+		return err
+	}
+	locked := lockedComposeArgs(ordered)
+
+	ui.Info("Starting %d element(s) with up to %d in parallel", len(ordered), parallel)
+
+	jobs := make(chan string)
+	results := make(chan *elementStartResult)
+	for i := 0; i < parallel; i++ {
+		go func() {
+			for element := range jobs {
+				result := &elementStartResult{element: element}
+				started := time.Now()
+				result.err = startElementTo(&result.output, project, env, element, true, locked[CanonicalElementName(element)])
+				if result.err == nil {
+					result.err = waitElementReady(&result.output, project, env, element, readyTimeout)
+				}
+				result.duration = time.Since(started)
+				results <- result
+			}
+		}()
+	}
+
+	ready := []string{}
+	for _, element := range ordered {
+		if pending[element] == 0 {
+			ready = append(ready, element)
+		}
+	}
+
+	status := map[string]string{}
+	durations := map[string]time.Duration{}
+	var firstErr error
+	running := 0
+	// inFlight marks elements whose compose run was already under way when
+	// the first failure stopped scheduling; they are allowed to finish.
+	inFlight := map[string]bool{}
+
+	for {
+		for firstErr == nil && len(ready) > 0 && running < parallel {
+			element := ready[0]
+			ready = ready[1:]
+			status[element] = "running"
+			running++
+			jobs <- element
+		}
+		if running == 0 {
+			break
+		}
+
+		result := <-results
+		running--
+		durations[result.element] = result.duration
+		if firstErr != nil {
+			inFlight[result.element] = true
+		}
+
+		ui.Section(result.element)
+		_, _ = os.Stdout.Write(result.output.Bytes())
+
+		if result.err != nil {
+			status[result.element] = "failed"
+			ui.Error("%s: %v", result.element, result.err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s failed to start: %w", result.element, result.err)
+				if running > 0 {
+					starting := []string{}
+					for _, element := range ordered {
+						if status[element] == "running" {
+							starting = append(starting, element)
+						}
+					}
+					ui.Warn("Scheduling stopped; waiting for %d element(s) already starting to finish: %s", running, strings.Join(starting, ", "))
+				}
+			}
+			continue
+		}
+		status[result.element] = "started"
+		for _, dependent := range dependents[result.element] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+	close(jobs)
+
+	printParallelStartSummary(ordered, status, durations, inFlight)
+	return firstErr
+}
+
+func prepareParallelStart(project string, env map[string]string, ordered []string, inSet map[string]bool) error {
+	needsDiscovery := false
+	for _, element := range ordered {
+		if elementRequiresMongoDiscovery(element) {
+			needsDiscovery = true
+		}
+		if element == "fingerprint-tuner" && !inSet["ollama"] {
+			if err := ensureOllamaStarted(project, env); err != nil {
+				return err
+			}
+		}
+	}
+	if needsDiscovery {
+		return ensureMongoServiceDiscovery(project, env)
+	}
+	return nil
+}
+
+func printParallelStartSummary(ordered []string, status map[string]string, durations map[string]time.Duration, inFlight map[string]bool) {
+	ui.Section("Start summary")
+	rows := make([][]string, 0, len(ordered))
+	started := 0
+	for _, element := range ordered {
+		state := status[element]
+		duration := "-"
+		switch state {
+		case "started":
+			started++
+			state = ui.Green(state)
+			duration = durations[element].Round(time.Second).String()
+		case "failed":
+			state = ui.Red(state)
+			duration = durations[element].Round(time.Second).String()
+		default:
+			state = ui.Yellow("not started")
+		}
+		if inFlight[element] {
+			state += " (finished after the failure)"
+		}
+		rows = append(rows, []string{element, state, duration})
+	}
+	ui.Table([]string{"ELEMENT", "RESULT", "DURATION"}, rows)
+	if started == len(ordered) {
+		return
+	}
+	if len(inFlight) > 0 {
+		ui.Info("Elements already starting when the first failure happened were allowed to finish; hbctl does not interrupt a running compose up")
+	}
+	notStarted := []string{}
+	for _, element := range ordered {
+		if status[element] != "started" {
+			notStarted = append(notStarted, element)
+		}
+	}
+	ui.Warn("%d of %d element(s) started; not started: %s", started, len(ordered), strings.Join(notStarted, ", "))
+}
@@ -0,0 +1,208 @@
+package local
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	hbmongo "github.com/herringbonedev/hbctl/internal/mongo"
+	"github.com/herringbonedev/hbctl/internal/ui"
+	"github.com/herringbonedev/hbctl/internal/units"
+)
+
+// DefaultReadyTimeout is how long lifecycle commands wait for an element's
+// readiness probe before failing.
+const DefaultReadyTimeout = 2 * time.Minute
+
+const readinessLogLines = 20
+
+// waitElementReady blocks until element passes its readiness probe. A zero
+// timeout disables the wait. Elements without a usable compose mapping were
+// skipped by the caller, so they are skipped here as well.
+func waitElementReady(w io.Writer, project string, env map[string]string, element string, timeout time.Duration) error {
+	element = CanonicalElementName(element)
+	if timeout <= 0 {
+		return nil
+	}
+	probe := units.ReadinessFor(element)
+	if probe.Kind == units.ProbeNone {
+		return nil
+	}
+	if IsEnterpriseElement(element) && !strings.EqualFold(strings.TrimSpace(env["HB_ENTERPRISE"]), "true") {
+		return nil
+	}
+	if start, _, err := shouldStartElement(element, ComposeFilesForElement(element)); err != nil || !start {
+		return nil
+	}
+
+	ui.FStep(w, "Waiting for %s to be ready (%s probe, timeout %s)", element, probe, timeout)
+	deadline := time.Now().Add(timeout)
+	detail := ""
+	for {
+		ready, msg := checkElementReady(project, env, element, probe)
+		if ready {
+			ui.FSuccess(w, "%s is ready", element)
+			return nil
+		}
+		detail = msg
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+
+	err := fmt.Sprintf("%s was not ready after %s (%s probe): %s", element, timeout, probe, detail)
+	if logs := recentElementLogs(project, element, readinessLogLines); logs != "" {
+		err += fmt.Sprintf("\nlast %d log lines from %s:\n%s", readinessLogLines, element, logs)
+	}
+	return errors.New(err)
+}
+
+// checkElementReady runs one probe attempt and returns a short reason when the
+// element is not ready yet.
+func checkElementReady(project string, env map[string]string, element string, probe units.ReadinessProbe) (bool, string) {
+	switch probe.Kind {
+	case units.ProbeHTTP:
+		url := serverURLPath(probe.Target)
+		client := &http.Client{Timeout: 2 * time.Second}
+		resp, err := client.Get(url)
+		if err != nil {
+			return false, err.Error()
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if !probe.AcceptsStatus(resp.StatusCode) {
+			return false, fmt.Sprintf("%s returned http %d", url, resp.StatusCode)
+		}
+		return true, ""
+	case units.ProbeTCP:
+		addr := probe.Target
+		if !strings.Contains(addr, ":") {
+			addr = "localhost:" + addr
+		}
+		conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
+		if err != nil {
+			return false, err.Error()
+		}
+		conn.Close()
+		return true, ""
+	case units.ProbeMongo:
+		sec := mongoSecretFromLifecycleEnv(env)
+		if sec == nil {
+			// Without credentials, fall back to the container state.
+			return dockerElementReady(project, element)
+		}
+		// Escape the credentials so passwords with @, :, / or % still parse.
+		userinfo := url.UserPassword(sec.User, sec.Password).String()
+		uri := fmt.Sprintf("mongodb://%s@%s:%d/%s?authSource=%s", userinfo, mongoHostForHbctl(sec.Host), sec.Port, url.PathEscape(sec.Database), url.QueryEscape(sec.AuthSource))
+		if hbmongo.CanConnect(uri) {
+			return true, ""
+		}
+		return false, "MongoDB ping failed"
+	default:
+		return dockerElementReady(project, element)
+	}
+}
+
+// dockerElementReady treats an element as ready when its containers are
+// running and, if the image defines a healthcheck, healthy.
+func dockerElementReady(project string, element string) (bool, string) {
+	containers, err := elementContainers(project, element)
+	if err != nil {
+		return false, err.Error()
+	}
+	return dockerContainersReady(containers)
+}
+
+func dockerContainersReady(containers []herringboneContainer) (bool, string) {
+	if len(containers) == 0 {
+		return false, "no container found"
+	}
+	// Stopped leftovers from earlier runs do not block readiness as long as at
+	// least one container is running and every running one is healthy.
+	running := 0
+	lastState := ""
+	for _, container := range containers {
+		state, health := dockerContainerHealth(container.ID)
+		if state != "running" {
+			lastState = fmt.Sprintf("%s is %s", container.Name, blankDefault(state, "unknown"))
+			continue
+		}
+		running++
+		if health != "" && health != "healthy" {
+			return false, fmt.Sprintf("%s health is %s", container.Name, health)
+		}
+	}
+	if running == 0 {
+		return false, lastState
+	}
+	return true, ""
+}
+
+// elementContainers returns the element's containers in the main project,
+// falling back to any Herringbone project when none are found there.
+func elementContainers(project string, element string) ([]herringboneContainer, error) {
+	containers, err := containersForService(project, element, true)
+	if err != nil {
+		return nil, err
+	}
+	return preferProjectContainers(project, containers), nil
+}
+
+func preferProjectContainers(project string, containers []herringboneContainer) []herringboneContainer {
+	mainProject := strings.ToLower(strings.TrimSpace(project))
+	if mainProject == "" {
+		mainProject = "herringbone"
+	}
+	inProject := []herringboneContainer{}
+	for _, container := range containers {
+		if container.Project == mainProject {
+			inProject = append(inProject, container)
+		}
+	}
+	if len(inProject) > 0 {
+		return inProject
+	}
+	return containers
+}
+
+func dockerContainerHealth(containerID string) (string, string) {
+	out := dockerInspectFormat(containerID, "{{.State.Status}} {{if .State.Health}}{{.State.Health.Status}}{{end}}")
+	fields := strings.Fields(out)
+	switch len(fields) {
+	case 0:
+		return "", ""
+	case 1:
+		return fields[0], ""
+	default:
+		return fields[0], fields[1]
+	}
+}
+
+// recentElementLogs returns the last lines logged by the element's first
+// container, or "" when there is nothing to show.
+func recentElementLogs(project string, element string, lines int) string {
+	containers, err := elementContainers(project, element)
+	if err != nil || len(containers) == 0 {
+		return ""
+	}
+	return recentContainerLogs(containers[0], lines)
+}
+
+func recentContainerLogs(container herringboneContainer, lines int) string {
+	target := blankDefault(container.ID, container.Name)
+	cmd := exec.Command("docker", "logs", "--tail", fmt.Sprintf("%d", lines), target)
+	cmd.Env = os.Environ()
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(string(out), "\n")
+}
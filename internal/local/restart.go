@@ -2,7 +2,9 @@ package local
 
 import (
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/herringbonedev/hbctl/internal/docker"
 	"github.com/herringbonedev/hbctl/internal/ui"
@@ -10,11 +12,13 @@ import (
 )
 
 type RestartOptions struct {
-	Project    string
-	Element    string
-	Unit       string
-	All        bool
-	Enterprise bool
+	Project      string
+	Element      string
+	Unit         string
+	All          bool
+	Enterprise   bool
+	Rolling      bool
+	ReadyTimeout time.Duration
 }
 
 func Restart(opts RestartOptions) error {
@@ -36,7 +40,10 @@ func Restart(opts RestartOptions) error {
 		if IsEnterpriseElement(element) && !opts.Enterprise {
 			return fmt.Errorf("%s is an enterprise service; pass --enterprise to restart it", element)
 		}
-		return restartElement(opts.Project, env, element)
+		if err := restartElement(opts, env, element); err != nil {
+			return err
+		}
+		return waitElementReady(os.Stdout, opts.Project, env, element, opts.ReadyTimeout)
 	}
 
 	if opts.Unit != "" {
@@ -50,7 +57,11 @@ func Restart(opts RestartOptions) error {
 		ui.Section("Unit")
 		ui.KeyValues([][2]string{{"unit", opts.Unit}, {"elements", fmt.Sprintf("%d", len(elements))}})
 		elements = filterEnterpriseElements(elements, opts.Enterprise)
-		operable, err := operableElements(elements)
+		ordered, err := buildElementGraph(env).order(elements)
+		if err != nil {
+			return err
+		}
+		operable, err := operableElements(ordered)
 		if err != nil {
 			return err
 		}
@@ -59,7 +70,10 @@ func Restart(opts RestartOptions) error {
 				ui.Skip("logingestion-receiver: use hbctl receiver restart")
 				continue
 			}
-			if err := restartElement(opts.Project, env, element); err != nil {
+			if err := restartElement(opts, env, element); err != nil {
+				return err
+			}
+			if err := waitElementReady(os.Stdout, opts.Project, env, element, opts.ReadyTimeout); err != nil {
 				return err
 			}
 		}
@@ -73,8 +87,15 @@ func Restart(opts RestartOptions) error {
 		}
 		ui.Section("Full stack")
 		ui.Step("Restarting all available services")
-		for _, element := range fullStackRestartElements(opts.Enterprise) {
-			if err := restartElement(opts.Project, env, element); err != nil {
+		ordered, err := buildElementGraph(env).order(fullStackRestartElements(opts.Enterprise))
+		if err != nil {
+			return err
+		}
+		for _, element := range ordered {
+			if err := restartElement(opts, env, element); err != nil {
+				return err
+			}
+			if err := waitElementReady(os.Stdout, opts.Project, env, element, opts.ReadyTimeout); err != nil {
 				return err
 			}
 		}
@@ -107,6 +128,9 @@ func restartTargetNeedsMongo(opts RestartOptions) bool {
 }
 
 func fullStackRestartElements(enterprise bool) []string {
+	if units.HasCatalog() {
+		return catalogFullStackElements(enterprise, true)
+	}
 	elements := []string{"mongodb", "herringbone-proxy", AuthElementForMode(enterprise), "herringbone-logs", "herringbone-search", "parser-cardset", "parser-extractor", "detectionengine-detector", "detectionengine-matcher", "detectionengine-ruleset", "incidents-incidentset", "incidents-correlator", "incidents-orchestrator", "operations-center"}
 	if enterprise {
 		elements = append(elements, "fingerprint-scoreset", "fingerprint-identifier", "ollama", "fingerprint-tuner", "parser-enrichment")
@@ -114,7 +138,8 @@ func fullStackRestartElements(enterprise bool) []string {
 	return elements
 }
 
-func restartElement(project string, env map[string]string, element string) error {
+func restartElement(opts RestartOptions, env map[string]string, element string) error {
+	project := opts.Project
 	element = CanonicalElementName(element)
 	if IsEnterpriseElement(element) && !strings.EqualFold(strings.TrimSpace(env["HB_ENTERPRISE"]), "true") {
 		ui.Skip("%s: enterprise service requires --enterprise", element)
@@ -133,9 +158,15 @@ func restartElement(project string, env map[string]string, element string) error
 			return err
 		}
 	}
+	if opts.Rolling {
+		restarted, err := rollingRestart(project, element, opts.ReadyTimeout)
+		if err != nil || restarted {
+			return err
+		}
+	}
 	ui.Step("Restarting %s", element)
 	composeArgs := []string{"-p", project}
-	composeArgs = append(composeArgs, ComposeFilesForElement(element)...)
+	composeArgs = append(composeArgs, withLockOverrides(ComposeFilesForElement(element))...)
 	service, err := resolveComposeServiceName(ComposeFilesForElement(element), element)
 	if err != nil {
 		return err
@@ -145,5 +176,17 @@ func restartElement(project string, env map[string]string, element string) error
 		return err
 	}
 	ui.Success("%s restarted", element)
+
+	// compose restart only touches existing containers, so bring the element
+	// back to the count set with hbctl scale if replicas went missing.
+	if replicas := configuredReplicas(project, element); replicas > 0 && !serviceHasFixedHostPort(element) {
+		running, err := containersForService(project, element, false)
+		if err != nil {
+			return err
+		}
+		if len(running) != replicas {
+			return scaleElement(project, env, element, replicas)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,175 @@
+package local
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/herringbonedev/hbctl/internal/docker"
+	"github.com/herringbonedev/hbctl/internal/ui"
+)
+
+// rollingSettle is how long a replacement must stay ready before the next
+// container is touched, so a container that crashes right after starting is
+// not counted as healthy.
+const rollingSettle = 3 * time.Second
+
+// rollingRestart restarts the element's running replicas one at a time and
+// waits for each to be healthy before the next. It returns false when the
+// element has fewer than two replicas so the caller can restart normally.
+func rollingRestart(project string, element string, timeout time.Duration) (bool, error) {
+	replicas, err := rollingReplicas(project, element)
+	if err != nil || len(replicas) == 0 {
+		return false, err
+	}
+	timeout = rollingTimeout(timeout)
+
+	ui.Step("Rolling restart of %s (%d replicas)", element, len(replicas))
+	for i, container := range replicas {
+		ui.Step("Restarting %s (%d/%d)", container.Name, i+1, len(replicas))
+		if err := restartContainers([]herringboneContainer{container}); err != nil {
+			return true, err
+		}
+		if err := waitContainerReady(container, timeout); err != nil {
+			return true, rollingFailure(element, err, len(replicas)-i-1, "restarted")
+		}
+		ui.Success("%s is healthy", container.Name)
+	}
+	ui.Success("%s restarted one replica at a time", element)
+	return true, nil
+}
+
+// rollingReplace replaces the element's running replicas one at a time: compose
+// adds one container from the current definition, hbctl waits for it to be
+// healthy, and then removes one old container. A replacement that never becomes
+// healthy is removed and the remaining old replicas are left running. It
+// returns false when the element has fewer than two replicas.
+func rollingReplace(project string, env map[string]string, element string, service string, composeArgs []string, timeout time.Duration, dryRun bool) (bool, error) {
+	replicas, err := rollingReplicas(project, element)
+	if err != nil || len(replicas) == 0 {
+		return false, err
+	}
+	timeout = rollingTimeout(timeout)
+	surge := strconv.Itoa(len(replicas) + 1)
+	upArgs := append([]string{}, composeArgs...)
+	upArgs = append(upArgs, "up", "-d", "--no-deps", "--no-recreate", "--scale", service+"="+surge, service)
+
+	ui.Step("Rolling replacement of %s (%d replicas)", element, len(replicas))
+	if dryRun {
+		for _, container := range replicas {
+			ui.Command("docker compose %s", strings.Join(upArgs, " "))
+			ui.Command("wait for the new %s container to be healthy", element)
+			ui.Command("docker stop %s && docker rm %s", container.Name, container.Name)
+		}
+		return true, nil
+	}
+
+	for i, old := range replicas {
+		before, err := containersForService(project, element, true)
+		if err != nil {
+			return true, err
+		}
+		known := map[string]bool{}
+		for _, container := range before {
+			known[containerKey(container)] = true
+		}
+
+		ui.Step("Adding a replacement for %s (%d/%d)", old.Name, i+1, len(replicas))
+		if err := docker.ComposeWithEnv(envWithSingleReplicaGuards(env, element), upArgs...); err != nil {
+			return true, rollingFailure(element, err, len(replicas)-i, "replaced")
+		}
+
+		after, err := containersForService(project, element, true)
+		if err != nil {
+			return true, err
+		}
+		var replacement *herringboneContainer
+		for _, container := range after {
+			if !known[containerKey(container)] {
+				replacement = &container
+				break
+			}
+		}
+		if replacement == nil {
+			return true, rollingFailure(element, errors.New("docker compose did not create a replacement container"), len(replicas)-i, "replaced")
+		}
+
+		if err := waitContainerReady(*replacement, timeout); err != nil {
+			ui.Warn("Removing unhealthy replacement %s", replacement.Name)
+			if stopErr := stopContainers([]herringboneContainer{*replacement}); stopErr == nil {
+				_ = removeContainers([]herringboneContainer{*replacement})
+			}
+			return true, rollingFailure(element, err, len(replicas)-i, "replaced")
+		}
+		ui.Success("%s is healthy; removing %s", replacement.Name, old.Name)
+		if err := stopContainers([]herringboneContainer{old}); err != nil {
+			return true, err
+		}
+		if err := removeContainers([]herringboneContainer{old}); err != nil {
+			return true, err
+		}
+	}
+	ui.Success("%s replaced one replica at a time", element)
+	return true, nil
+}
+
+// rollingReplicas returns the element's running containers in the main
+// project, or nil when a rolling operation does not apply.
+func rollingReplicas(project string, element string) ([]herringboneContainer, error) {
+	if serviceHasFixedHostPort(element) {
+		ui.Info("%s publishes a fixed host port and runs one replica; --rolling does not apply", element)
+		return nil, nil
+	}
+	replicas, err := runningProjectContainers(project, element)
+	if err != nil {
+		return nil, err
+	}
+	if len(replicas) < 2 {
+		ui.Info("%s runs %d replica(s); --rolling needs at least two, continuing without it", element, len(replicas))
+		return nil, nil
+	}
+	return replicas, nil
+}
+
+func rollingTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return DefaultReadyTimeout
+	}
+	return timeout
+}
+
+// waitContainerReady waits until one container is running and, if it has a
+// healthcheck, healthy, and stays that way for rollingSettle.
+func waitContainerReady(container herringboneContainer, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	detail := ""
+	for {
+		ready, msg := dockerContainersReady([]herringboneContainer{container})
+		if ready {
+			time.Sleep(rollingSettle)
+			if ready, msg = dockerContainersReady([]herringboneContainer{container}); ready {
+				return nil
+			}
+		}
+		detail = msg
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+
+	err := fmt.Sprintf("%s was not healthy after %s: %s", container.Name, timeout, detail)
+	if logs := recentContainerLogs(container, readinessLogLines); logs != "" {
+		err += fmt.Sprintf("\nlast %d log lines from %s:\n%s", readinessLogLines, container.Name, logs)
+	}
+	return errors.New(err)
+}
+
+func rollingFailure(element string, err error, remaining int, verb string) error {
+	if remaining <= 0 {
+		return fmt.Errorf("rolling update of %s stopped: %w", element, err)
+	}
+	return fmt.Errorf("rolling update of %s stopped; %d old replica(s) were not %s and are still running: %w", element, remaining, verb, err)
+}
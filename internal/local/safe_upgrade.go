@@ -0,0 +1,304 @@
+package local
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/herringbonedev/hbctl/internal/docker"
+	"github.com/herringbonedev/hbctl/internal/ui"
+	"gopkg.in/yaml.v3"
+)
+
+// safeUpgradeElement upgrades one element with an automatic way back. It
+// records the image the running containers use, pulls, and starts the new
+// image next to the old containers when the element publishes no fixed host
+// port, or in place when it does. If the new containers fail the readiness
+// probe, the image reference is pointed back at the recorded image and the
+// element is restored from it.
+func safeUpgradeElement(opts UpgradeOptions, env map[string]string, element string, service string, composeArgs []string) error {
+	old, err := runningProjectContainers(opts.Project, element)
+	if err != nil {
+		return err
+	}
+	if len(old) == 0 {
+		return fmt.Errorf("%s is not running; --safe upgrades a running element, use hbctl start --element %s first", element, element)
+	}
+
+	doc, err := composeConfigJSON(env, composeArgs)
+	if err != nil {
+		return err
+	}
+	imageRef := strings.TrimSpace(doc.Services[service].Image)
+	if imageRef == "" {
+		return fmt.Errorf("%s has no image reference in compose; --safe cannot record a previous image", element)
+	}
+	previousID := strings.TrimSpace(dockerInspectFormat(blankDefault(old[0].ID, old[0].Name), "{{.Image}}"))
+	if previousID == "" {
+		return fmt.Errorf("could not read the current image of %s", old[0].Name)
+	}
+
+	fixedPort := ""
+	if serviceHasFixedHostPort(element) {
+		fixedPort = "fixed"
+	} else if port, err := elementFixedHostPort(env, element); err == nil {
+		fixedPort = port
+	}
+	strategy := "side by side"
+	if fixedPort != "" {
+		strategy = "in place (publishes a fixed host port)"
+	}
+
+	ui.Section("Safe upgrade: " + element)
+	ui.KeyValues([][2]string{
+		{"image", imageRef},
+		{"previous", imageDigestLabel(previousID)},
+		{"replicas", strconv.Itoa(len(old))},
+		{"strategy", strategy},
+	})
+
+	upArgs := append([]string{}, composeArgs...)
+	upArgs = append(upArgs, "up", "-d", "--no-deps")
+	if opts.DryRun {
+		if opts.Pull {
+			ui.Command("docker compose %s", strings.Join(append(append([]string{}, composeArgs...), "pull", service), " "))
+		}
+		if fixedPort != "" {
+			ui.Command("docker compose %s", strings.Join(append(append([]string{}, upArgs...), "--force-recreate", service), " "))
+		} else {
+			ui.Command("docker compose %s", strings.Join(append(append([]string{}, upArgs...), "--no-recreate", "--scale", fmt.Sprintf("%s=%d", service, len(old)+1), service), " "))
+		}
+		if strings.Contains(imageRef, "@") {
+			ui.Command("run the %s readiness probe; on failure: recreate %s on %s through a temporary image override", element, element, shortImageID(previousID))
+		} else {
+			ui.Command("run the %s readiness probe; on failure: docker tag %s %s and recreate %s", element, shortImageID(previousID), imageRef, element)
+		}
+		return nil
+	}
+
+	if opts.Pull {
+		ui.Step("Pulling latest image for %s", service)
+		if err := docker.ComposeWithEnv(env, append(append([]string{}, composeArgs...), "pull", service)...); err != nil {
+			return err
+		}
+	}
+	newID := dockerImageID(imageRef)
+	if newID == previousID && !opts.ForceRecreate {
+		ui.Success("%s already runs %s; nothing to upgrade", element, imageDigestLabel(newID))
+		return nil
+	}
+
+	timeout := rollingTimeout(opts.ReadyTimeout)
+	var upgradeErr error
+	if fixedPort != "" {
+		upgradeErr = safeReplaceInPlace(opts, env, element, service, upArgs, timeout)
+	} else {
+		upgradeErr = safeReplaceSideBySide(opts, env, element, service, upArgs, old, timeout)
+	}
+
+	result := "upgraded"
+	if upgradeErr != nil {
+		ui.Error("%s failed its readiness probe: %v", element, upgradeErr)
+		result = "rolled back"
+		if err := restorePreviousImage(opts, env, element, service, composeArgs, imageRef, previousID, fixedPort != "", timeout); err != nil {
+			result = "rollback failed"
+			printSafeUpgradeResult(element, previousID, newID, result)
+			return fmt.Errorf("%s upgrade failed and the rollback to %s also failed: %v (upgrade error: %w)", element, imageDigestLabel(previousID), err, upgradeErr)
+		}
+	}
+	printSafeUpgradeResult(element, previousID, newID, result)
+	if upgradeErr != nil {
+		return fmt.Errorf("%s was rolled back to %s: %w", element, imageDigestLabel(previousID), upgradeErr)
+	}
+	return nil
+}
+
+// safeReplaceSideBySide adds one container from the new image next to the old
+// ones. Only when it is healthy are the old containers removed and the
+// replica count restored from the new image.
+func safeReplaceSideBySide(opts UpgradeOptions, env map[string]string, element string, service string, upArgs []string, old []herringboneContainer, timeout time.Duration) error {
+	known := map[string]bool{}
+	all, err := containersForService(opts.Project, element, true)
+	if err != nil {
+		return err
+	}
+	for _, container := range all {
+		known[containerKey(container)] = true
+	}
+
+	ui.Step("Starting the new %s image next to %d running container(s)", element, len(old))
+	args := append(append([]string{}, upArgs...), "--no-recreate", "--scale", fmt.Sprintf("%s=%d", service, len(old)+1), service)
+	if err := docker.ComposeWithEnv(envWithSingleReplicaGuards(env, element), args...); err != nil {
+		return err
+	}
+	all, err = containersForService(opts.Project, element, true)
+	if err != nil {
+		return err
+	}
+	var candidate *herringboneContainer
+	for _, container := range all {
+		if !known[containerKey(container)] {
+			candidate = &container
+			break
+		}
+	}
+	if candidate == nil {
+		return fmt.Errorf("docker compose did not create a new %s container", element)
+	}
+
+	if err := waitContainerReady(*candidate, timeout); err != nil {
+		ui.Warn("Removing new container %s; the old containers were not touched", candidate.Name)
+		if stopErr := stopContainers([]herringboneContainer{*candidate}); stopErr == nil {
+			_ = removeContainers([]herringboneContainer{*candidate})
+		}
+		return err
+	}
+
+	ui.Success("New container %s is healthy; removing the old container(s)", candidate.Name)
+	if err := stopContainers(old); err != nil {
+		return err
+	}
+	if err := removeContainers(old); err != nil {
+		return err
+	}
+	if len(old) > 1 {
+		args := append(append([]string{}, upArgs...), "--no-recreate", "--scale", fmt.Sprintf("%s=%d", service, len(old)), service)
+		if err := docker.ComposeWithEnv(envWithSingleReplicaGuards(env, element), args...); err != nil {
+			return err
+		}
+	}
+	return waitElementReady(os.Stdout, opts.Project, env, element, timeout)
+}
+
+// safeReplaceInPlace recreates the element on the new image; it is used when
+// a fixed host port rules out running old and new containers together.
+func safeReplaceInPlace(opts UpgradeOptions, env map[string]string, element string, service string, upArgs []string, timeout time.Duration) error {
+	ui.Step("Recreating %s in place on the new image", element)
+	args := append(append([]string{}, upArgs...), "--force-recreate")
+	args = append(args, scaleArgs(opts.Project, element, service)...)
+	args = append(args, service)
+	if err := docker.ComposeWithEnv(envWithSingleReplicaGuards(env, element), args...); err != nil {
+		return err
+	}
+	return waitElementReady(os.Stdout, opts.Project, env, element, timeout)
+}
+
+// restorePreviousImage points the compose image reference back at the
+// recorded image and brings the element back on it. Side-by-side upgrades
+// that failed before touching the old containers only need the retag. A
+// digest reference, as hbctl.lock pins, cannot be retagged, so any recreate
+// goes through a temporary override naming the previous image instead.
+func restorePreviousImage(opts UpgradeOptions, env map[string]string, element string, service string, composeArgs []string, imageRef string, previousID string, recreate bool, timeout time.Duration) error {
+	ui.Section("Rollback: " + element)
+	ui.Step("Restoring %s to %s", imageRef, imageDigestLabel(previousID))
+	if strings.Contains(imageRef, "@") {
+		override, err := writeImageOverride(service, previousID)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(override)
+		composeArgs = append(append([]string{}, composeArgs...), "-f", override)
+	} else {
+		cmd := exec.Command("docker", "tag", previousID, imageRef)
+		cmd.Env = os.Environ()
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("docker tag failed: %s", strings.TrimSpace(string(out)))
+		}
+	}
+
+	running, err := runningProjectContainers(opts.Project, element)
+	if err != nil {
+		return err
+	}
+	onPrevious := len(running) > 0
+	for _, container := range running {
+		if strings.TrimSpace(dockerInspectFormat(blankDefault(container.ID, container.Name), "{{.Image}}")) != previousID {
+			onPrevious = false
+		}
+	}
+	if onPrevious && !recreate {
+		ui.Success("%s is still running %s", element, imageDigestLabel(previousID))
+		return nil
+	}
+
+	ui.Step("Recreating %s on the previous image", element)
+	args := append(append([]string{}, composeArgs...), "up", "-d", "--no-deps", "--force-recreate")
+	args = append(args, scaleArgs(opts.Project, element, service)...)
+	args = append(args, service)
+	if err := docker.ComposeWithEnv(envWithSingleReplicaGuards(env, element), args...); err != nil {
+		return err
+	}
+	return waitElementReady(os.Stdout, opts.Project, env, element, timeout)
+}
+
+// writeImageOverride writes a temporary compose override that runs service
+// on image and returns its path.
+func writeImageOverride(service string, image string) (string, error) {
+	data, err := yaml.Marshal(map[string]any{"services": map[string]any{service: map[string]string{"image": image}}})
+	if err != nil {
+		return "", err
+	}
+	file, err := os.CreateTemp("", "hbctl-rollback-*.yaml")
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// runningProjectContainers returns the element's running containers in the
+// main compose project.
+func runningProjectContainers(project string, element string) ([]herringboneContainer, error) {
+	containers, err := containersForService(project, element, false)
+	if err != nil {
+		return nil, err
+	}
+	mainProject := strings.ToLower(strings.TrimSpace(project))
+	if mainProject == "" {
+		mainProject = "herringbone"
+	}
+	out := []herringboneContainer{}
+	for _, container := range containers {
+		if container.Project == mainProject {
+			out = append(out, container)
+		}
+	}
+	return out, nil
+}
+
+// imageDigestLabel shows an image as its repo digest when it has one, falling
+// back to the short image ID.
+func imageDigestLabel(imageID string) string {
+	if strings.TrimSpace(imageID) == "" {
+		return "unknown"
+	}
+	digests := dockerInspectFormat(imageID, `{{join .RepoDigests " "}}`)
+	if fields := strings.Fields(digests); len(fields) > 0 {
+		return fields[0]
+	}
+	return shortImageID(imageID)
+}
+
+func printSafeUpgradeResult(element string, previousID string, newID string, result string) {
+	switch result {
+	case "upgraded":
+		result = ui.Green(result)
+	case "rolled back":
+		result = ui.Yellow(result)
+	default:
+		result = ui.Red(result)
+	}
+	ui.Section("Safe upgrade result")
+	ui.Table([]string{"ELEMENT", "PREVIOUS", "NEW", "RESULT"}, [][]string{{element, imageDigestLabel(previousID), imageDigestLabel(newID), result}})
+}
@@ -0,0 +1,306 @@
+package local
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/herringbonedev/hbctl/internal/docker"
+	"github.com/herringbonedev/hbctl/internal/ui"
+)
+
+// replicasFile stores replica counts set with hbctl scale or hbctl apply so
+// later start and upgrade runs keep them. Like staged releases it lives in the
+// Herringbone directory hbctl runs from.
+var replicasFile = filepath.Join(".hbctl", "replicas.json")
+
+type ScaleOptions struct {
+	Project  string
+	Replicas map[string]int
+	// Reset removes stored counts so the elements go back to their compose
+	// default on their next start or upgrade.
+	Reset        []string
+	Enterprise   bool
+	ReadyTimeout time.Duration
+}
+
+type replicaStore struct {
+	Projects map[string]map[string]int `json:"projects"`
+}
+
+// Scale persists replica counts and applies them to running elements.
+// Elements that are not running keep the count for their next start. With no
+// counts or resets it lists the stored ones.
+func Scale(opts ScaleOptions) error {
+	if len(opts.Replicas) == 0 && len(opts.Reset) == 0 {
+		return listReplicaCounts(opts.Project)
+	}
+
+	ui.Header("Herringbone scale")
+
+	elements := make([]string, 0, len(opts.Replicas))
+	wanted := map[string]int{}
+	for name, count := range opts.Replicas {
+		element := CanonicalElementName(name)
+		if _, dup := wanted[element]; dup {
+			return fmt.Errorf("%s is listed more than once", element)
+		}
+		if err := validateReplicaTarget(element, count, opts.Enterprise); err != nil {
+			return err
+		}
+		wanted[element] = count
+		elements = append(elements, element)
+	}
+	sort.Strings(elements)
+	reset := make([]string, 0, len(opts.Reset))
+	for _, name := range opts.Reset {
+		element := CanonicalElementName(name)
+		if _, dup := wanted[element]; dup {
+			return fmt.Errorf("%s is listed more than once", element)
+		}
+		wanted[element] = 0
+		reset = append(reset, element)
+	}
+	sort.Strings(reset)
+
+	env, err := mongoLifecycleEnv(opts.Enterprise)
+	if err != nil {
+		return err
+	}
+	for _, element := range elements {
+		if port, err := elementFixedHostPort(env, element); err != nil {
+			return err
+		} else if port != "" && wanted[element] > 1 {
+			return fmt.Errorf("%s publishes fixed host port %s and cannot run more than one replica", element, port)
+		}
+	}
+
+	counts, err := loadReplicaCounts(opts.Project)
+	if err != nil {
+		return err
+	}
+	for _, element := range elements {
+		setReplicaCount(counts, element, wanted[element])
+	}
+	for _, element := range reset {
+		delete(counts, element)
+	}
+	if err := saveReplicaCounts(opts.Project, counts); err != nil {
+		return err
+	}
+	ui.Success("Saved replica counts to %s", replicasFile)
+	for _, element := range reset {
+		ui.Info("%s has no stored count; its next start or upgrade uses the compose default", element)
+	}
+
+	for _, element := range elements {
+		running, err := containersForService(opts.Project, element, false)
+		if err != nil {
+			return err
+		}
+		if len(running) == 0 {
+			ui.Info("%s is not running; %d replica(s) will be used on its next start", element, wanted[element])
+			continue
+		}
+		ui.Section(element)
+		if err := scaleElement(opts.Project, env, element, wanted[element]); err != nil {
+			return err
+		}
+		if err := waitElementReady(os.Stdout, opts.Project, env, element, opts.ReadyTimeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateReplicaTarget(element string, count int, enterprise bool) error {
+	switch {
+	case count < 1:
+		return fmt.Errorf("replicas for %s must be at least 1; use hbctl stop --element %s to stop it", element, element)
+	case element == "logingestion-receiver":
+		return fmt.Errorf("logingestion-receiver is managed separately; start more receivers with hbctl receiver start")
+	case isProtectedCoreService(element):
+		return fmt.Errorf("%s is protected core and always runs one replica", element)
+	case IsEnterpriseElement(element) && !enterprise:
+		return fmt.Errorf("%s is an enterprise service; pass --enterprise to scale it", element)
+	case count > 1 && serviceHasFixedHostPort(element):
+		return fmt.Errorf("%s publishes a fixed host port and cannot run more than one replica", element)
+	}
+	start, reason, err := shouldStartElement(element, ComposeFilesForElement(element))
+	if err != nil {
+		return err
+	}
+	if !start {
+		return fmt.Errorf("cannot scale %s: %s", element, reason)
+	}
+	return nil
+}
+
+// elementFixedHostPort returns the first host port the element's compose
+// service publishes, or "" when it publishes none.
+func elementFixedHostPort(env map[string]string, element string) (string, error) {
+	composeFiles := ComposeFilesForElement(element)
+	service, err := resolveComposeServiceName(composeFiles, element)
+	if err != nil {
+		return "", err
+	}
+	doc, err := composeConfigJSON(env, composeFiles)
+	if err != nil {
+		return "", err
+	}
+	for _, port := range doc.Services[service].Ports {
+		if published := strings.TrimSpace(port.Published); published != "" {
+			return published, nil
+		}
+	}
+	return "", nil
+}
+
+// scaleElement runs the element's compose service with an explicit replica
+// count. Elements that publish fixed host ports are refused.
+func scaleElement(project string, env map[string]string, element string, replicas int) error {
+	element = CanonicalElementName(element)
+	if replicas > 1 && serviceHasFixedHostPort(element) {
+		return fmt.Errorf("%s publishes a fixed host port and cannot run more than one replica", element)
+	}
+	if elementRequiresMongoDiscovery(element) {
+		if err := ensureMongoServiceDiscovery(project, env); err != nil {
+			return err
+		}
+	}
+	composeArgs := ComposeFilesForElement(element)
+	service, err := resolveComposeServiceName(composeArgs, element)
+	if err != nil {
+		return err
+	}
+	args := []string{"-p", project}
+	args = append(args, withLockOverrides(composeArgs)...)
+	args = append(args, "up", "-d", "--no-recreate", "--scale", service+"="+strconv.Itoa(replicas), service)
+	ui.Step("Scaling %s to %d replica(s)", element, replicas)
+	if err := docker.ComposeWithEnv(envWithSingleReplicaGuards(env, element), args...); err != nil {
+		return err
+	}
+	ui.Success("%s running %d replica(s)", element, replicas)
+	return nil
+}
+
+func listReplicaCounts(project string) error {
+	counts, err := loadReplicaCounts(project)
+	if err != nil {
+		return err
+	}
+	ui.Header("Herringbone replicas")
+	ui.KeyValues([][2]string{{"project", project}, {"file", replicasFile}})
+	if len(counts) == 0 {
+		ui.Info("No replica counts set; every element runs its compose default")
+		return nil
+	}
+	elements := make([]string, 0, len(counts))
+	for element := range counts {
+		elements = append(elements, element)
+	}
+	sort.Strings(elements)
+	rows := make([][]string, 0, len(elements))
+	for _, element := range elements {
+		running, err := containersForService(project, element, false)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, []string{element, strconv.Itoa(counts[element]), strconv.Itoa(len(running))})
+	}
+	ui.Table([]string{"ELEMENT", "REPLICAS", "RUNNING"}, rows)
+	return nil
+}
+
+// configuredReplicas returns the stored replica count for element, or 0 when
+// none is set or the store cannot be read.
+func configuredReplicas(project string, element string) int {
+	counts, err := loadReplicaCounts(project)
+	if err != nil {
+		return 0
+	}
+	return counts[CanonicalElementName(element)]
+}
+
+// scaleArgs returns the compose --scale flag for a stored replica count,
+// including 1, so a count set with hbctl scale wins over deploy.replicas.
+// Elements with fixed host ports are always forced to one replica by the
+// caller, so they never get one here.
+func scaleArgs(project string, element string, service string) []string {
+	if serviceHasFixedHostPort(element) {
+		return nil
+	}
+	if replicas := configuredReplicas(project, element); replicas > 0 {
+		return []string{"--scale", service + "=" + strconv.Itoa(replicas)}
+	}
+	return nil
+}
+
+// setReplicaCount stores an explicit count. Only hbctl scale element=default
+// removes one.
+func setReplicaCount(counts map[string]int, element string, replicas int) {
+	if replicas < 1 {
+		return
+	}
+	counts[element] = replicas
+}
+
+func replicaProjectKey(project string) string {
+	project = strings.ToLower(strings.TrimSpace(project))
+	if project == "" {
+		return "herringbone"
+	}
+	return project
+}
+
+func loadReplicaCounts(project string) (map[string]int, error) {
+	counts := map[string]int{}
+	data, err := os.ReadFile(replicasFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return counts, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var store replicaStore
+	if err := json.Unmarshal(data, &store); err != nil {
+		return nil, fmt.Errorf("invalid replica file %s: %w", replicasFile, err)
+	}
+	for element, replicas := range store.Projects[replicaProjectKey(project)] {
+		counts[element] = replicas
+	}
+	return counts, nil
+}
+
+func saveReplicaCounts(project string, counts map[string]int) error {
+	store := replicaStore{Projects: map[string]map[string]int{}}
+	if data, err := os.ReadFile(replicasFile); err == nil {
+		if err := json.Unmarshal(data, &store); err != nil {
+			return fmt.Errorf("invalid replica file %s: %w", replicasFile, err)
+		}
+		if store.Projects == nil {
+			store.Projects = map[string]map[string]int{}
+		}
+	}
+	key := replicaProjectKey(project)
+	if len(counts) == 0 {
+		delete(store.Projects, key)
+	} else {
+		store.Projects[key] = counts
+	}
+
+	if err := os.MkdirAll(filepath.Dir(replicasFile), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(replicasFile, append(data, '\n'), 0o644)
+}
@@ -24,22 +24,36 @@ import (
 )
 
 type StartOptions struct {
-	Project         string
-	SecretsDir      string
-	Element         string
-	Unit            string
-	All             bool
-	RecvType        string
-	TokenCreate     bool
-	NoTokenCreate   bool
-	BootstrapTokens bool
-	Enterprise      bool
+	Project    string
+	SecretsDir string
+	Element    string
+	Unit       string
+	// Elements starts an explicit set of elements, and their dependencies,
+	// in one ordered pass. apply uses it for the elements its plan starts.
+	Elements          []string
+	All               bool
+	RecvType          string
+	TokenCreate       bool
+	NoTokenCreate     bool
+	BootstrapTokens   bool
+	Enterprise        bool
+	Parallel          int
+	ReadyTimeout      time.Duration
+	RollbackOnFailure bool
+	// RollbackCore lets a rollback also remove protected core containers the
+	// failed start created. Volumes are still kept.
+	RollbackCore bool
 }
 
 type requestOptions struct {
 	OrgID string
 }
 
+// RuntimeSecretsDir returns the directory start writes runtime token files to.
+func RuntimeSecretsDir(override string) (string, error) {
+	return secretsDirForProject(override)
+}
+
 func secretsDirForProject(override string) (string, error) {
 	if strings.TrimSpace(override) != "" {
 		base, err := filepath.Abs(strings.TrimSpace(override))
@@ -121,75 +135,11 @@ func Start(opts StartOptions) error {
 	}
 
 	if opts.All {
-		ui.Section("Full stack")
-		plan := []string{
-			"Proxy is reused when an existing proxy container is present; otherwise hbctl creates one.",
-			"MongoDB is reused when an existing MongoDB container or volume is present; otherwise hbctl creates one without removing data.",
-			"Auth is reused when an existing auth container is present; otherwise hbctl creates one.",
-			"Enterprise services are included only when --enterprise is provided; hbctl does not rename compose services.",
-		}
-		plan = append(plan, "MongoDB init-mongo.js is replayed idempotently after MongoDB is reachable, so existing volumes still get default org/scopes/index initialization.")
-		if opts.Enterprise {
-			plan = append(plan, "Enterprise platform/org seed data is ensured only when --enterprise is provided.")
-		} else {
-			plan = append(plan, "Core/free mode skips enterprise platform/org seed data but still runs common init-mongo.js.")
-		}
-		plan = append(plan,
-			"Required service account token files are created before application services are started.",
-			"Application services are created or started after the protected core is ready.",
-			"Receivers are not started by --all; use hbctl receiver start so each receiver keeps its own compose project and port.",
-		)
-		ui.Plan("Start policy", plan)
-
-		if err := ensureCoreService(opts.Project, env, "herringbone-proxy"); err != nil {
-			return err
-		}
-
-		if err := ensureCoreDatabase(opts.Project, sec); err != nil {
-			return err
-		}
-
-		if err := ensureCommonMongoSeedData(opts.Project); err != nil {
-			return err
-		}
-
-		if opts.Enterprise {
-			if err := ensureEnterpriseMongoSeedData(opts.Project, sec); err != nil {
-				return err
-			}
-		} else {
-			ui.Skip("Enterprise platform/org seed data: core/free mode")
-		}
-
-		authElement := AuthElementForMode(opts.Enterprise)
-		if err := ensureCoreService(opts.Project, env, authElement); err != nil {
-			return err
-		}
-
-		if err := waitHTTP(serverURLPath("/health"), 45*time.Second); err != nil {
-			_ = waitHTTP(serverURLPath("/docs"), 5*time.Second)
-		}
-
-		if len(desiredServiceTokens) > 0 {
-			if secretsDir == "" || jwtSecret == nil {
-				return fmt.Errorf("service token bootstrap required but auth runtime secrets were not prepared")
-			}
-			if err := ensureServiceTokens(secretsDir, jwtSecret.JWTSecret, desiredServiceTokens, forceTokenRefresh); err != nil {
-				return err
-			}
-		} else if forceTokenRefresh {
-			ui.Info("No service tokens are required for this start target")
-		}
-
-		if err := cleanupMainProjectReceivers(opts.Project); err != nil {
-			return err
-		}
-
-		if err := startFullStackApplications(opts.Project, env, opts.Enterprise); err != nil {
-			return err
+		tx := beginStartTransaction(opts.Project)
+		defer tx.end()
+		if err := startFullStack(opts, env, sec, secretsDir, jwtSecret, desiredServiceTokens, forceTokenRefresh); err != nil {
+			return tx.rollbackOnFailure(err, opts.RollbackOnFailure, opts.RollbackCore)
 		}
-
-		ui.Success("Full Herringbone stack start complete")
 		return nil
 	}
 
@@ -219,6 +169,7 @@ func Start(opts StartOptions) error {
 			ui.Info("No service tokens are required for this start target")
 		}
 
+		targets := []string{}
 		for _, el := range elements {
 			element := CanonicalElementName(el)
 			if element == "logingestion-receiver" {
@@ -229,12 +180,61 @@ func Start(opts StartOptions) error {
 				ui.Skip("%s: enterprise service requires --enterprise", element)
 				continue
 			}
-			if err := startElement(opts.Project, env, element); err != nil {
+			targets = append(targets, element)
+		}
+
+		graph := buildElementGraph(env)
+		ordered, err := startDependencyClosure(graph, targets, opts.Enterprise)
+		if err != nil {
+			return err
+		}
+		printStartOrder(targets, ordered)
+		if err := startOrderedElements(opts.Project, env, graph, ordered, opts.Parallel, opts.ReadyTimeout); err != nil {
+			return err
+		}
+
+		ui.Success("Unit %s start complete", opts.Unit)
+		return nil
+	}
+
+	if len(opts.Elements) > 0 {
+		targets := []string{}
+		for _, el := range opts.Elements {
+			element := ElementForMode(el, opts.Enterprise)
+			if element == "logingestion-receiver" {
+				return fmt.Errorf("logingestion-receiver is managed separately; use hbctl receiver start --type <udp|tcp|http|remote>")
+			}
+			if IsEnterpriseElement(element) && !opts.Enterprise {
+				return fmt.Errorf("%s is an enterprise service; pass --enterprise to start it", element)
+			}
+			targets = append(targets, element)
+		}
+
+		if len(desiredServiceTokens) > 0 {
+			if secretsDir == "" || jwtSecret == nil {
+				return fmt.Errorf("service token bootstrap required but auth runtime secrets were not prepared")
+			}
+			if err := waitHTTP(serverURLPath("/health"), 45*time.Second); err != nil {
+				return err
+			}
+			if err := ensureServiceTokens(secretsDir, jwtSecret.JWTSecret, desiredServiceTokens, forceTokenRefresh); err != nil {
 				return err
 			}
+		} else if forceTokenRefresh {
+			ui.Info("No service tokens are required for this start target")
 		}
 
-		ui.Success("Unit %s start complete", opts.Unit)
+		graph := buildElementGraph(env)
+		ordered, err := startDependencyClosure(graph, targets, opts.Enterprise)
+		if err != nil {
+			return err
+		}
+		printStartOrder(targets, ordered)
+		if err := startOrderedElements(opts.Project, env, graph, ordered, opts.Parallel, opts.ReadyTimeout); err != nil {
+			return err
+		}
+
+		ui.Success("Elements %s start complete", strings.Join(targets, ", "))
 		return nil
 	}
 
@@ -265,7 +265,13 @@ func Start(opts StartOptions) error {
 			ui.Info("No service tokens are required for this start target")
 		}
 
-		if err := startElement(opts.Project, env, element); err != nil {
+		graph := buildElementGraph(env)
+		ordered, err := startDependencyClosure(graph, []string{element}, opts.Enterprise)
+		if err != nil {
+			return err
+		}
+		printStartOrder([]string{element}, ordered)
+		if err := startOrderedElements(opts.Project, env, graph, ordered, opts.Parallel, opts.ReadyTimeout); err != nil {
 			return err
 		}
 
@@ -276,6 +282,88 @@ func Start(opts StartOptions) error {
 	return fmt.Errorf("error: specify --element, --unit, or --all")
 }
 
+// startFullStack runs hbctl start --all. Start records the containers that
+// existed beforehand so a failure here can be rolled back.
+func startFullStack(opts StartOptions, env map[string]string, sec *secrets.MongoSecret, secretsDir string, jwtSecret *secrets.JWTSecret, desiredServiceTokens []ServiceIdentity, forceTokenRefresh bool) error {
+	ui.Section("Full stack")
+	plan := []string{
+		"Proxy is reused when an existing proxy container is present; otherwise hbctl creates one.",
+		"MongoDB is reused when an existing MongoDB container or volume is present; otherwise hbctl creates one without removing data.",
+		"Auth is reused when an existing auth container is present; otherwise hbctl creates one.",
+		"Enterprise services are included only when --enterprise is provided; hbctl does not rename compose services.",
+	}
+	plan = append(plan, "MongoDB init-mongo.js is replayed idempotently after MongoDB is reachable, so existing volumes still get default org/scopes/index initialization.")
+	if opts.Enterprise {
+		plan = append(plan, "Enterprise platform/org seed data is ensured only when --enterprise is provided.")
+	} else {
+		plan = append(plan, "Core/free mode skips enterprise platform/org seed data but still runs common init-mongo.js.")
+	}
+	plan = append(plan,
+		"Required service account token files are created before application services are started.",
+		"Application services are created or started after the protected core is ready.",
+		"Receivers are not started by --all; use hbctl receiver start so each receiver keeps its own compose project and port.",
+	)
+	ui.Plan("Start policy", plan)
+
+	if err := ensureCoreService(opts.Project, env, "herringbone-proxy"); err != nil {
+		return err
+	}
+	if err := waitElementReady(os.Stdout, opts.Project, env, "herringbone-proxy", opts.ReadyTimeout); err != nil {
+		return err
+	}
+
+	if err := ensureCoreDatabase(opts.Project, sec); err != nil {
+		return err
+	}
+
+	if err := ensureCommonMongoSeedData(opts.Project); err != nil {
+		return err
+	}
+
+	if opts.Enterprise {
+		if err := ensureEnterpriseMongoSeedData(opts.Project, sec); err != nil {
+			return err
+		}
+	} else {
+		ui.Skip("Enterprise platform/org seed data: core/free mode")
+	}
+
+	authElement := AuthElementForMode(opts.Enterprise)
+	if err := ensureCoreService(opts.Project, env, authElement); err != nil {
+		return err
+	}
+
+	if opts.ReadyTimeout > 0 {
+		if err := waitElementReady(os.Stdout, opts.Project, env, authElement, opts.ReadyTimeout); err != nil {
+			return err
+		}
+	} else if err := waitHTTP(serverURLPath("/health"), 45*time.Second); err != nil {
+		_ = waitHTTP(serverURLPath("/docs"), 5*time.Second)
+	}
+
+	if len(desiredServiceTokens) > 0 {
+		if secretsDir == "" || jwtSecret == nil {
+			return fmt.Errorf("service token bootstrap required but auth runtime secrets were not prepared")
+		}
+		if err := ensureServiceTokens(secretsDir, jwtSecret.JWTSecret, desiredServiceTokens, forceTokenRefresh); err != nil {
+			return err
+		}
+	} else if forceTokenRefresh {
+		ui.Info("No service tokens are required for this start target")
+	}
+
+	if err := cleanupMainProjectReceivers(opts.Project); err != nil {
+		return err
+	}
+
+	if err := startFullStackApplications(opts.Project, env, opts.Enterprise, opts.Parallel, opts.ReadyTimeout); err != nil {
+		return err
+	}
+
+	ui.Success("Full Herringbone stack start complete")
+	return nil
+}
+
 func startNeedsRuntimeSecrets(opts StartOptions, serviceTokens []ServiceIdentity, forceTokenRefresh bool) bool {
 	if opts.All || forceTokenRefresh || len(serviceTokens) > 0 {
 		return true
@@ -283,6 +371,11 @@ func startNeedsRuntimeSecrets(opts StartOptions, serviceTokens []ServiceIdentity
 	if CanonicalElementName(opts.Element) == "herringbone-auth" {
 		return true
 	}
+	for _, element := range opts.Elements {
+		if CanonicalElementName(element) == "herringbone-auth" {
+			return true
+		}
+	}
 	for _, element := range units.UnitElements[opts.Unit] {
 		if CanonicalElementName(element) == "herringbone-auth" {
 			return true
@@ -307,6 +400,9 @@ func serviceTokensForStart(opts StartOptions) []ServiceIdentity {
 	if opts.Element != "" {
 		elements = append(elements, ElementForMode(opts.Element, opts.Enterprise))
 	}
+	for _, element := range opts.Elements {
+		elements = append(elements, ElementForMode(element, opts.Enterprise))
+	}
 	if opts.Unit != "" {
 		if strings.TrimSpace(opts.Unit) == "auth" {
 			elements = append(elements, AuthElementForMode(opts.Enterprise))
@@ -732,15 +828,27 @@ func shouldStartElement(element string, composeArgs []string) (bool, string, err
 }
 
 func startElement(project string, env map[string]string, element string) error {
+	return startElementTo(os.Stdout, project, env, element, false, nil)
+}
+
+// startElementTo starts one element with all output sent to w. When prepared
+// is true the caller has already ensured MongoDB service discovery and
+// Ollama, which is how parallel starts avoid repeating that work per element.
+// locked, when not nil, holds the element's compose arguments with lock
+// overrides already written by lockedComposeArgs.
+func startElementTo(w io.Writer, project string, env map[string]string, element string, prepared bool, locked []string) error {
 	element = CanonicalElementName(element)
-	if element == "fingerprint-tuner" {
-		if err := ensureOllamaStarted(project, env); err != nil {
-			return err
+	defer trackStart(project, element)()
+	if !prepared {
+		if element == "fingerprint-tuner" {
+			if err := ensureOllamaStarted(project, env); err != nil {
+				return err
+			}
 		}
-	}
-	if elementRequiresMongoDiscovery(element) {
-		if err := ensureMongoServiceDiscovery(project, env); err != nil {
-			return err
+		if elementRequiresMongoDiscovery(element) {
+			if err := ensureMongoServiceDiscovery(project, env); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -750,22 +858,27 @@ func startElement(project string, env map[string]string, element string) error {
 		return err
 	}
 	if !start {
-		ui.Skip("%s: %s", element, reason)
+		ui.FSkip(w, "%s: %s", element, reason)
 		return nil
 	}
+	if locked != nil {
+		composeArgs = locked
+	} else {
+		composeArgs = withLockOverrides(composeArgs)
+	}
 
 	service, err := resolveComposeServiceName(composeArgs, element)
 	if err != nil {
 		if requiredStartElement(element) {
 			return err
 		}
-		ui.Skip("%s: %v", element, err)
+		ui.FSkip(w, "%s: %v", element, err)
 		return nil
 	}
 
 	serviceEnv := envWithSingleReplicaGuards(env, element)
 	if serviceHasFixedHostPort(element) {
-		if err := pruneStoppedContainers(pruneContainerOptions{Project: project, IncludeProtected: false, Services: []string{element}, QuietIfEmpty: true}); err != nil {
+		if err := pruneStoppedContainers(pruneContainerOptions{Project: project, IncludeProtected: false, Services: []string{element}, QuietIfEmpty: true, Out: w}); err != nil {
 			return err
 		}
 
@@ -774,8 +887,8 @@ func startElement(project string, env map[string]string, element string) error {
 			return err
 		}
 		if len(existing) > 0 {
-			ui.Success("Existing %s container already owns its fixed host port; reusing it", element)
-			printContainerReuseTable(existing)
+			ui.FSuccess(w, "Existing %s container already owns its fixed host port; reusing it", element)
+			fprintContainerReuseTable(w, existing)
 			return nil
 		}
 	}
@@ -790,20 +903,28 @@ func startElement(project string, env map[string]string, element string) error {
 	}
 	if serviceHasFixedHostPort(element) {
 		args = append(args, "--scale", service+"=1")
-		ui.Info("%s publishes a fixed host port; forcing one replica to prevent port conflicts", element)
+		ui.FInfo(w, "%s publishes a fixed host port; forcing one replica to prevent port conflicts", element)
+	} else if scale := scaleArgs(project, element, service); len(scale) > 0 {
+		args = append(args, scale...)
+		ui.FInfo(w, "%s uses the replica count set with hbctl scale (%s)", element, scale[1])
 	}
 	args = append(args, service)
 
 	if service != element {
-		ui.Step("Starting %s using compose service %s", element, service)
+		ui.FStep(w, "Starting %s using compose service %s", element, service)
 	} else {
-		ui.Step("Starting %s", element)
+		ui.FStep(w, "Starting %s", element)
 	}
-	if err := docker.ComposeWithEnv(serviceEnv, args...); err != nil {
+	if w == io.Writer(os.Stdout) {
+		err = docker.ComposeWithEnv(serviceEnv, args...)
+	} else {
+		err = docker.ComposeWithEnvTo(w, serviceEnv, args...)
+	}
+	if err != nil {
 		return err
 	}
 	if element == "ollama" {
-		return ensureOllamaModel(project)
+		return ensureOllamaModelTo(w, project)
 	}
 	return nil
 }
@@ -820,9 +941,9 @@ func ensureOllamaStarted(project string, env map[string]string) error {
 	return startElement(project, env, "ollama")
 }
 
-func ensureOllamaModel(project string) error {
+func ensureOllamaModelTo(w io.Writer, project string) error {
 	if strings.EqualFold(strings.TrimSpace(os.Getenv("HBCTL_SKIP_OLLAMA_PULL")), "true") {
-		ui.Skip("ollama model pull: HBCTL_SKIP_OLLAMA_PULL=true")
+		ui.FSkip(w, "ollama model pull: HBCTL_SKIP_OLLAMA_PULL=true")
 		return nil
 	}
 
@@ -840,26 +961,29 @@ func ensureOllamaModel(project string) error {
 		}
 	}
 	if containerID == "" {
-		ui.Warn("ollama model pull skipped: no running ollama container found")
+		ui.FWarn(w, "ollama model pull skipped: no running ollama container found")
 		return nil
 	}
 
 	listCmd := exec.Command("docker", "exec", containerID, "ollama", "list")
 	listOut, listErr := listCmd.CombinedOutput()
 	if listErr == nil && strings.Contains(string(listOut), model) {
-		ui.Success("Ollama model already available: %s", model)
+		ui.FSuccess(w, "Ollama model already available: %s", model)
 		return nil
 	}
 
-	ui.Step("Pulling Ollama model %s", model)
+	ui.FStep(w, "Pulling Ollama model %s", model)
 	pullCmd := exec.Command("docker", "exec", containerID, "ollama", "pull", model)
-	pullCmd.Stdout = os.Stdout
-	pullCmd.Stderr = os.Stderr
-	pullCmd.Stdin = os.Stdin
+	pullCmd.Stdout = w
+	pullCmd.Stderr = w
+	if w == io.Writer(os.Stdout) {
+		pullCmd.Stderr = os.Stderr
+		pullCmd.Stdin = os.Stdin
+	}
 	if err := pullCmd.Run(); err != nil {
 		return fmt.Errorf("failed to pull Ollama model %s: %w", model, err)
 	}
-	ui.Success("Ollama model ready: %s", model)
+	ui.FSuccess(w, "Ollama model ready: %s", model)
 	return nil
 }
 
@@ -1030,6 +1154,7 @@ func fixedHostPortReplicaEnvKeys(element string) []string {
 func ensureCoreService(project string, env map[string]string, element string) error {
 	element = CanonicalElementName(element)
 	ui.Section("Protected core: " + element)
+	defer trackStart(project, element)()
 
 	existing, err := containersForExactService(project, element, true)
 	if err != nil {
@@ -1060,6 +1185,7 @@ func ensureCoreService(project string, env map[string]string, element string) er
 
 func ensureCoreDatabase(project string, sec *secrets.MongoSecret) error {
 	ui.Section("Protected core: mongodb")
+	defer trackStart(project, "mongodb")()
 	existing, err := containersForExactService(project, "mongodb", true)
 	if err != nil {
 		return err
@@ -1075,8 +1201,9 @@ func ensureCoreDatabase(project string, sec *secrets.MongoSecret) error {
 	return ensureDatabase(project, sec)
 }
 
-func startFullStackApplications(project string, env map[string]string, enterprise bool) error {
+func startFullStackApplications(project string, env map[string]string, enterprise bool, parallel int, readyTimeout time.Duration) error {
 	ui.Section("Application services")
+	elements := []string{}
 	for _, e := range units.AllElements {
 		element := CanonicalElementName(e.Name)
 		if isProtectedCoreService(element) {
@@ -1090,11 +1217,36 @@ func startFullStackApplications(project string, env map[string]string, enterpris
 			ui.Skip("logingestion-receiver: receivers are managed separately with hbctl receiver start/list/stop")
 			continue
 		}
-		if err := startElement(project, env, element); err != nil {
-			return err
+		elements = append(elements, element)
+	}
+
+	graph := buildElementGraph(env)
+	ordered, err := graph.order(elements)
+	if err != nil {
+		return err
+	}
+	return startOrderedElements(project, env, graph, ordered, parallel, readyTimeout)
+}
+
+// printStartOrder shows the dependency-first start order when the graph
+// pulled in elements beyond the ones requested.
+func printStartOrder(targets []string, ordered []string) {
+	if len(ordered) <= len(targets) {
+		return
+	}
+	requested := map[string]bool{}
+	for _, target := range targets {
+		requested[CanonicalElementName(target)] = true
+	}
+	items := make([]string, 0, len(ordered))
+	for _, element := range ordered {
+		if requested[element] {
+			items = append(items, element)
+		} else {
+			items = append(items, element+" (dependency)")
 		}
 	}
-	return nil
+	ui.Plan("Start order", items)
 }
 
 func ensureFullStackReceiver(project string, env map[string]string) error {
@@ -1179,11 +1331,15 @@ func cleanupMainProjectReceivers(project string) error {
 }
 
 func printContainerReuseTable(containers []herringboneContainer) {
+	fprintContainerReuseTable(os.Stdout, containers)
+}
+
+func fprintContainerReuseTable(w io.Writer, containers []herringboneContainer) {
 	rows := make([][]string, 0, len(containers))
 	for _, container := range containers {
 		rows = append(rows, []string{container.Service, container.Project, container.State, container.Status, container.Name})
 	}
-	ui.Table([]string{"SERVICE", "PROJECT", "STATE", "STATUS", "NAME"}, rows)
+	ui.FTable(w, []string{"SERVICE", "PROJECT", "STATE", "STATUS", "NAME"}, rows)
 }
 
 func ensureCommonMongoSeedData(project string) error {
@@ -1563,11 +1719,8 @@ func ensureDatabase(project string, sec *secrets.MongoSecret) error {
 	if _, err := os.Stat(ComposeMongo); err != nil {
 		return fmt.Errorf("required mongodb compose file missing: %s", ComposeMongo)
 	}
-	if err := docker.ComposeWithEnv(env,
-		"-p", project,
-		"-f", ComposeMongo,
-		"up", "-d", "--no-recreate", "mongodb",
-	); err != nil {
+	mongoArgs := append([]string{"-p", project}, withLockOverrides([]string{"-f", ComposeMongo})...)
+	if err := docker.ComposeWithEnv(env, append(mongoArgs, "up", "-d", "--no-recreate", "mongodb")...); err != nil {
 		return err
 	}
 
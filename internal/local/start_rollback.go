@@ -0,0 +1,235 @@
+package local
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/herringbonedev/hbctl/internal/ui"
+	"golang.org/x/term"
+)
+
+// startTransaction records the containers a start creates, and the stopped
+// containers it starts, as each step runs, so a failed start can be undone
+// without touching anything that was already there.
+type startTransaction struct {
+	project string
+	mu      sync.Mutex
+	seen    map[string]bool
+	created []herringboneContainer
+	started []herringboneContainer
+}
+
+// activeStart is the transaction of the start --all in progress, if any.
+var (
+	activeStartMu sync.Mutex
+	activeStart   *startTransaction
+)
+
+func beginStartTransaction(project string) *startTransaction {
+	tx := &startTransaction{project: project, seen: map[string]bool{}}
+	activeStartMu.Lock()
+	activeStart = tx
+	activeStartMu.Unlock()
+	return tx
+}
+
+func (tx *startTransaction) end() {
+	activeStartMu.Lock()
+	if activeStart == tx {
+		activeStart = nil
+	}
+	activeStartMu.Unlock()
+}
+
+// trackStart snapshots element's containers and returns a func that records,
+// in the active transaction, the ones created or started since. It is a no-op
+// outside a transaction. Safe to call from parallel start workers.
+func trackStart(project string, element string) func() {
+	activeStartMu.Lock()
+	tx := activeStart
+	activeStartMu.Unlock()
+	if tx == nil {
+		return func() {}
+	}
+	before, err := containersForService(project, element, true)
+	if err != nil {
+		return func() {}
+	}
+	previous := map[string]herringboneContainer{}
+	for _, container := range before {
+		previous[containerKey(container)] = container
+	}
+	return func() {
+		after, err := containersForService(project, element, true)
+		if err != nil {
+			ui.Warn("Could not record %s containers for rollback: %v", element, err)
+			return
+		}
+		tx.mu.Lock()
+		defer tx.mu.Unlock()
+		for _, container := range after {
+			key := containerKey(container)
+			if tx.seen[key] {
+				continue
+			}
+			old, existed := previous[key]
+			switch {
+			case !existed:
+				tx.created = append(tx.created, container)
+			case !isRunningContainer(old) && isRunningContainer(container):
+				tx.started = append(tx.started, container)
+			default:
+				continue
+			}
+			tx.seen[key] = true
+		}
+	}
+}
+
+func containerKey(container herringboneContainer) string {
+	if id := strings.TrimSpace(container.ID); id != "" {
+		return id
+	}
+	return strings.TrimSpace(container.Name)
+}
+
+// changes returns the containers this start created and the pre-existing
+// containers it started, most recent first so dependents go before the core
+// they rely on. States are refreshed so stopped containers are not stopped
+// again.
+func (tx *startTransaction) changes() ([]herringboneContainer, []herringboneContainer) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	current := map[string]herringboneContainer{}
+	if containers, err := listHerringboneContainers(tx.project, true); err == nil {
+		for _, container := range containers {
+			current[containerKey(container)] = container
+		}
+	}
+	refresh := func(list []herringboneContainer) []herringboneContainer {
+		out := make([]herringboneContainer, 0, len(list))
+		for i := len(list) - 1; i >= 0; i-- {
+			container := list[i]
+			if latest, ok := current[containerKey(container)]; ok {
+				container = latest
+			}
+			out = append(out, container)
+		}
+		return out
+	}
+	return refresh(tx.created), refresh(tx.started)
+}
+
+// rollbackOnFailure reports what the failed start changed and, when confirmed
+// or forced with --rollback-on-failure, reverts it. Containers hbctl created
+// are stopped and removed, pre-existing containers it started are stopped
+// again, protected core is left running unless includeCore (--rollback-core)
+// allows removing the core containers this start created, and volumes are
+// never removed. The original start error is always returned.
+func (tx *startTransaction) rollbackOnFailure(startErr error, force bool, includeCore bool) error {
+	created, started := tx.changes()
+
+	ui.Section("Failed start changes")
+
+	toRemove := []herringboneContainer{}
+	toStop := []herringboneContainer{}
+	protected := []herringboneContainer{}
+	rows := [][]string{}
+	for _, container := range created {
+		action := "stop and remove"
+		switch {
+		case !isProtectedCoreService(container.Service):
+			toRemove = append(toRemove, container)
+		case includeCore:
+			action = "stop and remove (--rollback-core; volumes kept)"
+			toRemove = append(toRemove, container)
+		default:
+			action = "leave running (protected core)"
+			protected = append(protected, container)
+		}
+		rows = append(rows, []string{container.Service, "created", action, container.Name})
+	}
+	for _, container := range started {
+		action := "stop"
+		if isProtectedCoreService(container.Service) {
+			action = "leave running (protected core)"
+			protected = append(protected, container)
+		} else {
+			toStop = append(toStop, container)
+		}
+		rows = append(rows, []string{container.Service, "started", action, container.Name})
+	}
+
+	if len(rows) == 0 {
+		ui.Info("The failed start did not create or start any containers")
+		return startErr
+	}
+	ui.Table([]string{"SERVICE", "CHANGE", "ROLLBACK", "NAME"}, rows)
+
+	if len(toRemove) == 0 && len(toStop) == 0 {
+		ui.Info("Only protected core was changed; nothing to roll back")
+		return startErr
+	}
+
+	if !force {
+		if !confirmRollback() {
+			ui.Info("Rollback skipped. Rerun with --rollback-on-failure to revert automatically, or fix the error and run hbctl start --all again.")
+			return startErr
+		}
+	}
+
+	ui.Section("Rollback")
+	ui.Warn("Removing containers only. Docker volumes are not removed.")
+	running := []herringboneContainer{}
+	for _, container := range toRemove {
+		if isRunningContainer(container) {
+			running = append(running, container)
+		}
+	}
+	if len(running) > 0 || len(toStop) > 0 {
+		ui.Step("Stopping container(s) changed by the failed start")
+		if err := stopContainers(append(running, toStop...)); err != nil {
+			ui.Warn("Rollback stop failed: %v", err)
+			return startErr
+		}
+	}
+	if len(toRemove) > 0 {
+		ui.Step("Removing container(s) created by the failed start")
+		if err := removeContainers(toRemove); err != nil {
+			ui.Warn("Rollback remove failed: %v", err)
+			return startErr
+		}
+	}
+	ui.Success("Rolled back %d created and %d started container(s)", len(toRemove), len(toStop))
+
+	if len(protected) > 0 {
+		ui.Section("Protected core left running")
+		tableRows := make([][]string, 0, len(protected))
+		for _, container := range protected {
+			tableRows = append(tableRows, []string{container.Service, container.Project, container.State, container.Name})
+		}
+		ui.Table([]string{"SERVICE", "PROJECT", "STATE", "NAME"}, tableRows)
+		ui.Info("Use hbctl stop --mongo, --proxy, or --auth if you also want these stopped, or rerun start with --rollback-core to remove core containers a failed start created.")
+	}
+	return startErr
+}
+
+func confirmRollback() bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false
+	}
+	fmt.Print("Roll back the containers this start created or started? [y/N]: ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
@@ -19,6 +19,9 @@ type StopOptions struct {
 	Mongo          bool
 	Auth           bool
 	KeepContainers bool
+	// WithDependents also stops the running application elements that
+	// depend on Element, in reverse dependency order.
+	WithDependents bool
 }
 
 func Stop(opts StopOptions) error {
@@ -26,7 +29,7 @@ func Stop(opts StopOptions) error {
 	ui.Header("Herringbone stop")
 
 	if opts.Element != "" {
-		return stopElement(opts.Project, env, opts.Element, !opts.KeepContainers)
+		return stopElementAndDependents(opts.Project, env, opts.Element, opts.WithDependents, !opts.KeepContainers)
 	}
 
 	if opts.Unit != "" {
@@ -36,7 +39,11 @@ func Stop(opts StopOptions) error {
 		}
 		ui.Section("Unit")
 		ui.KeyValues([][2]string{{"unit", opts.Unit}, {"elements", fmt.Sprintf("%d", len(elements))}})
-		operable, err := operableElements(elements)
+		ordered, err := buildElementGraph(env).reverseOrder(elements)
+		if err != nil {
+			return err
+		}
+		operable, err := operableElements(ordered)
 		if err != nil {
 			return err
 		}
@@ -138,7 +145,11 @@ func stopApplicationContainers(project string, env map[string]string, down bool,
 	// names can drift, but that must never prevent the final container sweep from
 	// stopping app containers such as operations-center replicas.
 	composeStopWarnings := []string{}
-	for _, element := range fullStackStopElements() {
+	stopOrder, err := buildElementGraph(env).reverseOrder(fullStackStopElements())
+	if err != nil {
+		return err
+	}
+	for _, element := range stopOrder {
 		if err := stopElement(project, env, element, !keepContainers); err != nil {
 			msg := fmt.Sprintf("%s: %v", element, err)
 			composeStopWarnings = append(composeStopWarnings, msg)
@@ -215,6 +226,15 @@ func stopDiscoveredApplicationContainers(project string) error {
 }
 
 func fullStackStopElements() []string {
+	if units.HasCatalog() {
+		out := []string{}
+		for _, element := range units.CatalogElementNames() {
+			if !isProtectedCoreService(element) {
+				out = append(out, element)
+			}
+		}
+		return out
+	}
 	return []string{
 		"herringbone-logs",
 		"herringbone-search",
@@ -236,6 +256,52 @@ func fullStackStopElements() []string {
 	}
 }
 
+// stopElementAndDependents stops element. Running application elements that
+// depend on it are only reported unless withDependents is set, in which case
+// they are stopped first. Protected core is never stopped implicitly; when
+// element is protected, its running dependents are only reported.
+func stopElementAndDependents(project string, env map[string]string, element string, withDependents bool, prune bool) error {
+	element = CanonicalElementName(element)
+	graph := buildElementGraph(env)
+
+	running := []string{}
+	for _, dependent := range graph.dependents(element) {
+		if isProtectedCoreService(dependent) || dependent == "logingestion-receiver" {
+			continue
+		}
+		containers, err := containersForService(project, dependent, false)
+		if err != nil {
+			return err
+		}
+		if len(containers) > 0 {
+			running = append(running, dependent)
+		}
+	}
+
+	if len(running) > 0 && isProtectedCoreService(element) {
+		ui.Warn("%s is protected core; dependents left running: %s", element, strings.Join(running, ", "))
+		running = nil
+	} else if len(running) > 0 && !withDependents {
+		ui.Warn("Running elements that depend on %s are left running and may fail: %s", element, strings.Join(running, ", "))
+		ui.Info("Add --with-dependents to stop them first")
+		running = nil
+	}
+
+	ordered, err := graph.reverseOrder(append(running, element))
+	if err != nil {
+		return err
+	}
+	if len(ordered) > 1 {
+		ui.Plan("Stop order", ordered)
+	}
+	for _, el := range ordered {
+		if err := stopElement(project, env, el, prune); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func stopElement(project string, env map[string]string, element string, prune bool) error {
 	element = CanonicalElementName(element)
 	start, reason, err := shouldStartElement(element, ComposeFilesForElement(element))
@@ -2,7 +2,9 @@ package local
 
 import (
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/herringbonedev/hbctl/internal/docker"
 	"github.com/herringbonedev/hbctl/internal/secrets"
@@ -19,6 +21,9 @@ type UpgradeOptions struct {
 	ForceRecreate bool
 	Enterprise    bool
 	DryRun        bool
+	Rolling       bool
+	Safe          bool
+	ReadyTimeout  time.Duration
 }
 
 func Upgrade(opts UpgradeOptions) error {
@@ -43,7 +48,10 @@ func Upgrade(opts UpgradeOptions) error {
 		if IsEnterpriseElement(element) && !opts.Enterprise {
 			return fmt.Errorf("%s is an enterprise service; pass --enterprise to upgrade it", element)
 		}
-		return upgradeElement(opts.Project, env, element, opts.Pull, opts.ForceRecreate, opts.DryRun, true)
+		if err := upgradeElement(opts, env, element, true); err != nil {
+			return err
+		}
+		return waitUpgradedElementReady(opts, env, element)
 	}
 
 	if opts.Unit != "" {
@@ -56,8 +64,11 @@ func Upgrade(opts UpgradeOptions) error {
 		}
 		ui.Section("Upgrade target")
 		ui.KeyValues([][2]string{{"unit", opts.Unit}, {"elements", fmt.Sprintf("%d", len(elements))}})
-		for _, element := range elements {
-			element = CanonicalElementName(element)
+		ordered, err := buildElementGraph(env).order(elements)
+		if err != nil {
+			return err
+		}
+		for _, element := range ordered {
 			if element == "logingestion-receiver" {
 				ui.Skip("logingestion-receiver: use hbctl receiver commands")
 				continue
@@ -66,7 +77,10 @@ func Upgrade(opts UpgradeOptions) error {
 				ui.Skip("%s: enterprise service requires --enterprise", element)
 				continue
 			}
-			if err := upgradeElement(opts.Project, env, element, opts.Pull, opts.ForceRecreate, opts.DryRun, false); err != nil {
+			if err := upgradeElement(opts, env, element, false); err != nil {
+				return err
+			}
+			if err := waitUpgradedElementReady(opts, env, element); err != nil {
 				return err
 			}
 		}
@@ -102,8 +116,15 @@ func Upgrade(opts UpgradeOptions) error {
 			return err
 		}
 
-		for _, element := range fullStackUpgradeElements(opts.Enterprise) {
-			if err := upgradeElement(opts.Project, env, element, opts.Pull, opts.ForceRecreate, opts.DryRun, false); err != nil {
+		ordered, err := buildElementGraph(env).order(fullStackUpgradeElements(opts.Enterprise))
+		if err != nil {
+			return err
+		}
+		for _, element := range ordered {
+			if err := upgradeElement(opts, env, element, false); err != nil {
+				return err
+			}
+			if err := waitUpgradedElementReady(opts, env, element); err != nil {
 				return err
 			}
 		}
@@ -114,16 +135,52 @@ func Upgrade(opts UpgradeOptions) error {
 	return fmt.Errorf("specify --element, --unit, or --all")
 }
 
+// mongoMigration is one MongoDB seed or migration step of the upgrade refresh.
+type mongoMigration struct {
+	name       string
+	plan       string
+	enterprise bool
+	run        func(project string, sec *secrets.MongoSecret) error
+}
+
+// mongoMigrations are the steps runUpgradeMongoSeedPlan runs, in order.
+// Release manifests name the ones a release depends on, so adding a step here
+// is all it takes for hbctl to advertise it.
+var mongoMigrations = []mongoMigration{
+	{
+		name: "init-mongo-replay",
+		plan: "replay init-mongo.js inside the running MongoDB container",
+		run:  func(project string, _ *secrets.MongoSecret) error { return ensureCommonMongoSeedData(project) },
+	},
+	{
+		name:       "enterprise-platform-org",
+		plan:       "ensure enterprise platform/org seed data",
+		enterprise: true,
+		run:        ensureEnterpriseMongoSeedData,
+	},
+}
+
+// MongoMigrationNames returns the names of the MongoDB seed and migration
+// steps the upgrade refresh runs.
+func MongoMigrationNames() []string {
+	names := make([]string, 0, len(mongoMigrations))
+	for _, migration := range mongoMigrations {
+		names = append(names, migration.name)
+	}
+	return names
+}
+
 func runUpgradeMongoSeedPlan(project string, env map[string]string, enterprise bool, dryRun bool) error {
 	ui.Section("MongoDB seed/migration data")
 
 	if dryRun {
 		ui.Command("ensure MongoDB is reachable without recreating or removing volumes")
-		ui.Command("replay init-mongo.js inside the running MongoDB container")
-		if enterprise {
-			ui.Command("ensure enterprise platform/org seed data")
-		} else {
-			ui.Skip("Enterprise platform/org seed data: core/free mode")
+		for _, migration := range mongoMigrations {
+			if migration.enterprise && !enterprise {
+				ui.Skip("%s: core/free mode", migration.name)
+				continue
+			}
+			ui.Command("%s", migration.plan)
 		}
 		return nil
 	}
@@ -146,13 +203,15 @@ func runUpgradeMongoSeedPlan(project string, env map[string]string, enterprise b
 	if err := ensureCoreDatabase(project, sec); err != nil {
 		return err
 	}
-	if err := ensureCommonMongoSeedData(project); err != nil {
-		return err
-	}
-	if enterprise {
-		return ensureEnterpriseMongoSeedData(project, sec)
+	for _, migration := range mongoMigrations {
+		if migration.enterprise && !enterprise {
+			ui.Skip("%s: core/free mode", migration.name)
+			continue
+		}
+		if err := migration.run(project, sec); err != nil {
+			return err
+		}
 	}
-	ui.Skip("Enterprise platform/org seed data: core/free mode")
 	return nil
 }
 
@@ -174,12 +233,17 @@ func printUpgradeHeader(opts UpgradeOptions) {
 		{"project", opts.Project},
 		{"pull images", ui.Bool(opts.Pull)},
 		{"force recreate", ui.Bool(opts.ForceRecreate)},
+		{"rolling", ui.Bool(opts.Rolling)},
+		{"safe", ui.Bool(opts.Safe)},
 		{"enterprise env", ui.Bool(opts.Enterprise)},
 		{"dry run", ui.Bool(opts.DryRun)},
 	})
 }
 
 func fullStackUpgradeElements(enterprise bool) []string {
+	if units.HasCatalog() {
+		return catalogFullStackElements(enterprise, false)
+	}
 	elements := []string{
 		"herringbone-proxy",
 		AuthElementForMode(enterprise),
@@ -201,7 +265,7 @@ func fullStackUpgradeElements(enterprise bool) []string {
 	return elements
 }
 
-func upgradeElement(project string, env map[string]string, element string, pull bool, forceRecreate bool, dryRun bool, explicit bool) error {
+func upgradeElement(opts UpgradeOptions, env map[string]string, element string, explicit bool) error {
 	element = CanonicalElementName(element)
 	if IsEnterpriseElement(element) && !strings.EqualFold(strings.TrimSpace(env["HB_ENTERPRISE"]), "true") {
 		if explicit {
@@ -230,19 +294,30 @@ func upgradeElement(project string, env map[string]string, element string, pull
 	}
 
 	ui.Section(element)
-	if elementRequiresMongoDiscovery(element) && !dryRun {
-		if err := ensureMongoServiceDiscovery(project, env); err != nil {
+	if elementRequiresMongoDiscovery(element) && !opts.DryRun {
+		if err := ensureMongoServiceDiscovery(opts.Project, env); err != nil {
 			return err
 		}
 	}
-	composeArgs := []string{"-p", project}
-	composeArgs = append(composeArgs, composeFiles...)
+	composeArgs := []string{"-p", opts.Project}
+	composeArgs = append(composeArgs, withLockOverrides(composeFiles)...)
+
+	if opts.Safe {
+		return safeUpgradeElement(opts, env, element, service, composeArgs)
+	}
 
-	if pull {
+	if opts.Pull {
 		ui.Step("Pulling latest image for %s", service)
 		pullArgs := append([]string{}, composeArgs...)
 		pullArgs = append(pullArgs, "pull", service)
-		if err := runComposeMaybe(dryRun, env, pullArgs...); err != nil {
+		if err := runComposeMaybe(opts.DryRun, env, pullArgs...); err != nil {
+			return err
+		}
+	}
+
+	if opts.Rolling {
+		replaced, err := rollingReplace(opts.Project, env, element, service, composeArgs, opts.ReadyTimeout, opts.DryRun)
+		if err != nil || replaced {
 			return err
 		}
 	}
@@ -250,17 +325,25 @@ func upgradeElement(project string, env map[string]string, element string, pull
 	ui.Step("Recreating without tearing down dependencies")
 	upArgs := append([]string{}, composeArgs...)
 	upArgs = append(upArgs, "up", "-d", "--no-deps")
-	if forceRecreate {
+	if opts.ForceRecreate {
 		upArgs = append(upArgs, "--force-recreate")
 	}
+	upArgs = append(upArgs, scaleArgs(opts.Project, element, service)...)
 	upArgs = append(upArgs, service)
-	if err := runComposeMaybe(dryRun, env, upArgs...); err != nil {
+	if err := runComposeMaybe(opts.DryRun, env, upArgs...); err != nil {
 		return err
 	}
 	ui.Success("%s upgraded", element)
 	return nil
 }
 
+func waitUpgradedElementReady(opts UpgradeOptions, env map[string]string, element string) error {
+	if opts.DryRun {
+		return nil
+	}
+	return waitElementReady(os.Stdout, opts.Project, env, element, opts.ReadyTimeout)
+}
+
 func runComposeMaybe(dryRun bool, env map[string]string, args ...string) error {
 	if dryRun {
 		ui.Command("docker compose %s", strings.Join(args, " "))
@@ -0,0 +1,229 @@
+package local
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/herringbonedev/hbctl/internal/ui"
+	"github.com/herringbonedev/hbctl/internal/units"
+)
+
+// DefaultWaitTimeout is how long hbctl wait and --wait block by default.
+const DefaultWaitTimeout = 5 * time.Minute
+
+type WaitOptions struct {
+	Project    string
+	Element    string
+	Unit       string
+	All        bool
+	Enterprise bool
+	Timeout    time.Duration
+	JSON       bool
+	Out        io.Writer
+}
+
+type ElementWaitStatus struct {
+	Element    string `json:"element"`
+	Probe      string `json:"probe"`
+	Ready      bool   `json:"ready"`
+	Containers int    `json:"containers"`
+	Running    int    `json:"running"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+type WaitReport struct {
+	Ready    bool                `json:"ready"`
+	Elapsed  string              `json:"elapsed"`
+	Timeout  string              `json:"timeout"`
+	Elements []ElementWaitStatus `json:"elements"`
+}
+
+// Wait blocks until every target element passes its readiness probe or the
+// timeout expires. It returns an error listing the elements that never became
+// ready, so scripts can rely on the exit status.
+func Wait(opts WaitOptions) error {
+	out := opts.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultWaitTimeout
+	}
+
+	targets, err := waitTargets(opts)
+	if err != nil {
+		return err
+	}
+
+	env := blankLifecycleEnv(opts.Enterprise)
+	for _, element := range targets {
+		if units.ReadinessFor(element).Kind == units.ProbeMongo {
+			// Without stored credentials the mongo probe falls back to the
+			// container state, so a missing secret is not an error here.
+			if loaded, err := mongoLifecycleEnv(opts.Enterprise); err == nil {
+				env = loaded
+			}
+			break
+		}
+	}
+
+	if !opts.JSON {
+		ui.FHeader(out, "Herringbone wait")
+		ui.FKeyValues(out, [][2]string{
+			{"project", opts.Project},
+			{"elements", fmt.Sprintf("%d", len(targets))},
+			{"timeout", opts.Timeout.String()},
+		})
+		ui.FStep(out, "Waiting for %s", strings.Join(targets, ", "))
+	}
+
+	started := time.Now()
+	deadline := started.Add(opts.Timeout)
+	var statuses []ElementWaitStatus
+	for {
+		statuses, err = pollElementReadiness(opts.Project, env, targets)
+		if err != nil {
+			return err
+		}
+		if allElementsReady(statuses) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(2 * time.Second)
+	}
+
+	report := WaitReport{
+		Ready:    allElementsReady(statuses),
+		Elapsed:  time.Since(started).Round(time.Second).String(),
+		Timeout:  opts.Timeout.String(),
+		Elements: statuses,
+	}
+
+	if opts.JSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		rows := make([][]string, 0, len(statuses))
+		for _, status := range statuses {
+			state := ui.Green("ready")
+			if !status.Ready {
+				state = ui.Red("not ready")
+			}
+			rows = append(rows, []string{status.Element, status.Probe, state, fmt.Sprintf("%d/%d", status.Running, status.Containers), status.Detail})
+		}
+		ui.FTable(out, []string{"ELEMENT", "PROBE", "STATE", "RUNNING", "DETAIL"}, rows)
+	}
+
+	if report.Ready {
+		if !opts.JSON {
+			ui.FSuccess(out, "%d element(s) ready after %s", len(statuses), report.Elapsed)
+		}
+		return nil
+	}
+
+	notReady := []string{}
+	for _, status := range statuses {
+		if !status.Ready {
+			notReady = append(notReady, status.Element)
+		}
+	}
+	return fmt.Errorf("%d element(s) not ready after %s: %s", len(notReady), opts.Timeout, strings.Join(notReady, ", "))
+}
+
+func waitTargets(opts WaitOptions) ([]string, error) {
+	var candidates []string
+	switch {
+	case strings.TrimSpace(opts.Element) != "":
+		element := ElementForMode(opts.Element, opts.Enterprise)
+		if IsEnterpriseElement(element) && !opts.Enterprise {
+			return nil, fmt.Errorf("%s is an enterprise service; pass --enterprise to wait for it", element)
+		}
+		return []string{element}, nil
+	case strings.TrimSpace(opts.Unit) != "":
+		candidates = units.UnitElements[strings.TrimSpace(opts.Unit)]
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("unknown unit: %s", opts.Unit)
+		}
+	case opts.All:
+		candidates = []string{"mongodb", "herringbone-proxy", AuthElementForMode(opts.Enterprise)}
+		for _, element := range units.AllElements {
+			candidates = append(candidates, element.Name)
+		}
+	default:
+		return nil, fmt.Errorf("specify --element, --unit, or --all")
+	}
+
+	targets := []string{}
+	for _, element := range uniqueStrings(candidates) {
+		element = CanonicalElementName(element)
+		if element == "logingestion-receiver" {
+			continue
+		}
+		if IsEnterpriseElement(element) && !opts.Enterprise {
+			continue
+		}
+		if start, _, err := shouldStartElement(element, ComposeFilesForElement(element)); err != nil || !start {
+			continue
+		}
+		targets = append(targets, element)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no elements with compose files to wait for")
+	}
+	return targets, nil
+}
+
+// pollElementReadiness lists containers once and checks every target against
+// that snapshot; http, tcp, and mongo probes are checked directly.
+func pollElementReadiness(project string, env map[string]string, targets []string) ([]ElementWaitStatus, error) {
+	containers, err := listHerringboneContainers(project, true)
+	if err != nil {
+		return nil, err
+	}
+	byElement := map[string][]herringboneContainer{}
+	for _, container := range containers {
+		element := CanonicalElementName(container.Service)
+		byElement[element] = append(byElement[element], container)
+	}
+
+	statuses := make([]ElementWaitStatus, 0, len(targets))
+	for _, element := range targets {
+		probe := units.ReadinessFor(element)
+		owned := preferProjectContainers(project, byElement[element])
+		status := ElementWaitStatus{Element: element, Probe: probe.String(), Containers: len(owned)}
+		for _, container := range owned {
+			if isRunningContainer(container) {
+				status.Running++
+			}
+		}
+
+		switch probe.Kind {
+		case units.ProbeNone:
+			status.Ready = status.Running > 0
+			if !status.Ready {
+				status.Detail = "no running container"
+			}
+		case units.ProbeDocker:
+			status.Ready, status.Detail = dockerContainersReady(owned)
+		default:
+			status.Ready, status.Detail = checkElementReady(project, env, element, probe)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func allElementsReady(statuses []ElementWaitStatus) bool {
+	for _, status := range statuses {
+		if !status.Ready {
+			return false
+		}
+	}
+	return len(statuses) > 0
+}
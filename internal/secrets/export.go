@@ -0,0 +1,455 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// exportKind marks the decrypted payload of an export bundle, so a
+// secrets.enc cannot be imported by mistake and vice versa.
+const (
+	exportKind    = "hbctl-secrets-export"
+	exportVersion = 1
+)
+
+// Import policies for entries present both locally and in the bundle.
+const (
+	ImportMerge   = "merge"
+	ImportReplace = "replace"
+)
+
+// Import actions reported per entry and runtime file.
+const (
+	ImportAdd      = "add"
+	ImportSame     = "unchanged"
+	ImportConflict = "conflict"
+)
+
+type ExportOptions struct {
+	Output string
+	// RuntimeDir, when set, adds the runtime token files under it.
+	RuntimeDir string
+	Force      bool
+}
+
+type ImportOptions struct {
+	Path string
+	// Entries limits the import to these entry names; empty means all.
+	Entries []string
+	Policy  string
+	// RuntimeDir, when set, restores the bundle's runtime token files there.
+	RuntimeDir string
+}
+
+// ExportSummary lists what an export bundle holds, by name only.
+type ExportSummary struct {
+	Path    string
+	Entries []string
+	Runtime []string
+}
+
+// ImportItem is one planned change. Runtime files are named runtime/<file>.
+type ImportItem struct {
+	Name   string
+	Action string
+	// Write is false for unchanged items and for conflicts under merge.
+	Write bool
+}
+
+// ImportPlan is computed before anything is written so conflicts can be
+// reported first; ApplyImport writes it.
+type ImportPlan struct {
+	Created string
+	Policy  string
+	Items   []ImportItem
+
+	bundle     exportBundle
+	runtimeDir string
+}
+
+type exportBundle struct {
+	Kind    string            `json:"kind"`
+	Version int               `json:"version"`
+	Created string            `json:"created"`
+	Store   Store             `json:"store"`
+	Runtime map[string][]byte `json:"runtime,omitempty"`
+}
+
+// storeField reads and copies one Store entry, so export and import treat
+// every entry the same way.
+type storeField struct {
+	name string
+	get  func(*Store) any
+	set  func(dst *Store, src *Store)
+}
+
+var storeFields = []storeField{
+	{EntryMongoDB, func(s *Store) any {
+		if s.MongoDB == nil {
+			return nil
+		}
+		return s.MongoDB
+	}, func(dst *Store, src *Store) { dst.MongoDB = src.MongoDB }},
+	{EntryMongoRootPassword, func(s *Store) any {
+		if s.MongoRootPassword == "" {
+			return nil
+		}
+		return s.MongoRootPassword
+	}, func(dst *Store, src *Store) { dst.MongoRootPassword = src.MongoRootPassword }},
+	{EntryJWTSecret, func(s *Store) any {
+		if s.JWTSecret == nil {
+			return nil
+		}
+		return s.JWTSecret
+	}, func(dst *Store, src *Store) { dst.JWTSecret = src.JWTSecret }},
+	{EntryServiceKey, func(s *Store) any {
+		if s.ServiceKey == nil {
+			return nil
+		}
+		return s.ServiceKey
+	}, func(dst *Store, src *Store) { dst.ServiceKey = src.ServiceKey }},
+	{EntryAuthToken, func(s *Store) any {
+		if s.AuthToken == nil {
+			return nil
+		}
+		return s.AuthToken
+	}, func(dst *Store, src *Store) { dst.AuthToken = src.AuthToken }},
+	{EntryServer, func(s *Store) any {
+		if s.Server == nil {
+			return nil
+		}
+		return s.Server
+	}, func(dst *Store, src *Store) { dst.Server = src.Server }},
+}
+
+// StoreEntryNames lists the entry names export and import accept.
+func StoreEntryNames() []string {
+	names := make([]string, 0, len(storeFields))
+	for _, field := range storeFields {
+		names = append(names, field.name)
+	}
+	return names
+}
+
+// Export writes secrets.enc, and optionally the runtime token files, to a
+// bundle encrypted with a separate export passphrase taken from
+// HBCTL_EXPORT_PASSPHRASE or a confirmed prompt.
+func Export(opts ExportOptions) (*ExportSummary, error) {
+	if strings.TrimSpace(opts.Output) == "" {
+		return nil, errors.New("an output path is required")
+	}
+	if _, err := os.Stat(opts.Output); err == nil && !opts.Force {
+		return nil, fmt.Errorf("%s already exists; pass --force to overwrite it", opts.Output)
+	}
+
+	store, err := loadStore()
+	if err != nil {
+		return nil, err
+	}
+	bundle := exportBundle{
+		Kind:    exportKind,
+		Version: exportVersion,
+		Created: time.Now().UTC().Format(time.RFC3339),
+		Store:   *store,
+	}
+	summary := &ExportSummary{Path: opts.Output}
+	for _, field := range storeFields {
+		if field.get(store) != nil {
+			summary.Entries = append(summary.Entries, field.name)
+		}
+	}
+
+	if opts.RuntimeDir != "" {
+		bundle.Runtime, err = readRuntimeFiles(opts.RuntimeDir)
+		if err != nil {
+			return nil, err
+		}
+		for name := range bundle.Runtime {
+			summary.Runtime = append(summary.Runtime, name)
+		}
+		sort.Strings(summary.Runtime)
+	}
+
+	storeKey, err := unlockStore(false)
+	if err != nil {
+		return nil, err
+	}
+	pass, err := exportPassphrase(true)
+	if err != nil {
+		return nil, err
+	}
+	if storeKey.pass != "" && pass == storeKey.pass {
+		return nil, errors.New("use an export passphrase different from the hbctl passphrase")
+	}
+
+	plain, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := encrypt(plain, pass)
+	if err != nil {
+		return nil, err
+	}
+	tmp := opts.Output + ".tmp"
+	if err := os.WriteFile(tmp, enc, 0600); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, opts.Output); err != nil {
+		_ = os.Remove(tmp)
+		return nil, err
+	}
+	return summary, nil
+}
+
+// PlanImport decrypts the bundle and compares it with secrets.enc and the
+// runtime directory. Nothing is written.
+func PlanImport(opts ImportOptions) (*ImportPlan, error) {
+	policy := strings.TrimSpace(opts.Policy)
+	if policy == "" {
+		policy = ImportMerge
+	}
+	if policy != ImportMerge && policy != ImportReplace {
+		return nil, fmt.Errorf("unknown import policy %q; use %s or %s", policy, ImportMerge, ImportReplace)
+	}
+	selected := map[string]bool{}
+	for _, name := range opts.Entries {
+		name = strings.TrimSpace(name)
+		known := false
+		for _, field := range storeFields {
+			known = known || field.name == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown entry %q; choose from %s", name, strings.Join(StoreEntryNames(), ", "))
+		}
+		selected[name] = true
+	}
+
+	data, err := os.ReadFile(opts.Path)
+	if err != nil {
+		return nil, err
+	}
+	pass, err := exportPassphrase(false)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := decrypt(data, pass)
+	if err != nil {
+		return nil, errors.New("failed to decrypt the bundle (wrong export passphrase?)")
+	}
+	var bundle exportBundle
+	if err := json.Unmarshal(plain, &bundle); err != nil || bundle.Kind != exportKind {
+		return nil, fmt.Errorf("%s is not an hbctl secrets export", opts.Path)
+	}
+	if bundle.Version > exportVersion {
+		return nil, fmt.Errorf("export version %d is not supported by this hbctl; upgrade hbctl", bundle.Version)
+	}
+
+	local := &Store{}
+	path, err := secretsPath()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err == nil {
+		if local, err = loadStore(); err != nil {
+			return nil, err
+		}
+	}
+
+	plan := &ImportPlan{Created: bundle.Created, Policy: policy, bundle: bundle, runtimeDir: opts.RuntimeDir}
+	for _, field := range storeFields {
+		incoming := field.get(&bundle.Store)
+		if incoming == nil || (len(selected) > 0 && !selected[field.name]) {
+			continue
+		}
+		plan.Items = append(plan.Items, plannedItem(field.name, field.get(local), incoming, policy))
+	}
+	for name := range selected {
+		found := false
+		for _, item := range plan.Items {
+			found = found || item.Name == name
+		}
+		if !found {
+			return nil, fmt.Errorf("the bundle has no %s entry", name)
+		}
+	}
+
+	if opts.RuntimeDir != "" {
+		names := make([]string, 0, len(bundle.Runtime))
+		for name := range bundle.Runtime {
+			if !validRuntimeName(name) {
+				return nil, fmt.Errorf("bundle has an unsafe runtime file name %q", name)
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			var existing any
+			if current, err := os.ReadFile(filepath.Join(opts.RuntimeDir, filepath.FromSlash(name))); err == nil {
+				existing = current
+			}
+			plan.Items = append(plan.Items, plannedItem("runtime/"+name, existing, bundle.Runtime[name], policy))
+		}
+	}
+	return plan, nil
+}
+
+// ApplyImport writes the planned entries to secrets.enc and the runtime files
+// to their directory. When an existing secrets.enc changes, it is first kept
+// as secrets.enc.<timestamp>.bak, which hbctl secrets rekey --confirm removes.
+func ApplyImport(plan *ImportPlan) (string, error) {
+	path, err := secretsPath()
+	if err != nil {
+		return "", err
+	}
+
+	storeChanged := false
+	for _, item := range plan.Items {
+		storeChanged = storeChanged || (item.Write && !strings.HasPrefix(item.Name, "runtime/"))
+	}
+
+	backup := ""
+	if storeChanged {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return "", err
+		}
+		local := &Store{}
+		current, err := os.ReadFile(path)
+		firstTime := os.IsNotExist(err)
+		if err != nil && !firstTime {
+			return "", err
+		}
+		key, err := unlockStore(firstTime)
+		if err != nil {
+			return "", err
+		}
+		if !firstTime {
+			if local, err = loadStore(); err != nil {
+				return "", err
+			}
+		}
+		for _, item := range plan.Items {
+			if !item.Write {
+				continue
+			}
+			for _, field := range storeFields {
+				if field.name != item.Name {
+					continue
+				}
+				field.set(local, &plan.bundle.Store)
+				if saved := plan.bundle.Store.Saved[field.name]; saved != "" {
+					if local.Saved == nil {
+						local.Saved = map[string]string{}
+					}
+					local.Saved[field.name] = saved
+				} else {
+					local.markSaved(field.name)
+				}
+			}
+		}
+
+		plain, err := json.MarshalIndent(local, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		enc, err := key.seal(plain)
+		if err != nil {
+			return "", err
+		}
+		if !firstTime {
+			backup = fmt.Sprintf("%s.%s.bak", path, time.Now().UTC().Format("20060102T150405Z"))
+			if err := os.WriteFile(backup, current, 0600); err != nil {
+				return "", err
+			}
+		}
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, enc, 0600); err != nil {
+			return "", err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			_ = os.Remove(tmp)
+			return "", err
+		}
+	}
+
+	for _, item := range plan.Items {
+		name, ok := strings.CutPrefix(item.Name, "runtime/")
+		if !ok || !item.Write {
+			continue
+		}
+		dst := filepath.Join(plan.runtimeDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+			return backup, err
+		}
+		tmp := dst + ".tmp"
+		if err := os.WriteFile(tmp, plan.bundle.Runtime[name], 0600); err != nil {
+			return backup, err
+		}
+		if err := os.Rename(tmp, dst); err != nil {
+			_ = os.Remove(tmp)
+			return backup, err
+		}
+	}
+	return backup, nil
+}
+
+func plannedItem(name string, existing any, incoming any, policy string) ImportItem {
+	if existing == nil {
+		return ImportItem{Name: name, Action: ImportAdd, Write: true}
+	}
+	left, _ := json.Marshal(existing)
+	right, _ := json.Marshal(incoming)
+	if bytes.Equal(left, right) {
+		return ImportItem{Name: name, Action: ImportSame}
+	}
+	return ImportItem{Name: name, Action: ImportConflict, Write: policy == ImportReplace}
+}
+
+func readRuntimeFiles(dir string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("runtime secrets directory %s does not exist", dir)
+	}
+	return files, err
+}
+
+func validRuntimeName(name string) bool {
+	return name != "" && path.Clean(name) == name && !path.IsAbs(name) &&
+		name != ".." && !strings.HasPrefix(name, "../") && !strings.Contains(name, `\`)
+}
+
+// exportPassphrase returns the bundle passphrase from HBCTL_EXPORT_PASSPHRASE
+// or a prompt. It is never cached, so it cannot be mistaken for the hbctl
+// passphrase.
+func exportPassphrase(confirm bool) (string, error) {
+	if v := os.Getenv("HBCTL_EXPORT_PASSPHRASE"); v != "" {
+		return v, nil
+	}
+	return promptPassphrase("export passphrase", confirm)
+}
@@ -0,0 +1,204 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// secrets.enc starts with a header naming the key derivation and its
+// parameters, so they can change without breaking existing files:
+//
+//	magic "HBSE" | version u8 | kdf u8 | param1 u32 | param2 u32 | param3 u32 |
+//	salt length u8 | salt | nonce | AES-256-GCM ciphertext
+//
+// The header through the salt is authenticated as additional data. Files
+// written before the header existed are salt || nonce || ciphertext with
+// scrypt N=1<<15, r=8, p=1; they still decrypt and are rewritten in the
+// current format on the next save.
+const (
+	formatVersion = 1
+
+	kdfScrypt   = 1
+	kdfArgon2id = 2
+
+	headerFixedSize = 4 + 1 + 1 + 12 + 1
+)
+
+var formatMagic = []byte("HBSE")
+
+// kdfParams are the key derivation settings stored in the header. For scrypt
+// they are N, r, p; for Argon2id time, memory in KiB, and threads.
+type kdfParams struct {
+	ID     uint8
+	First  uint32
+	Second uint32
+	Third  uint32
+}
+
+var (
+	legacyKDF  = kdfParams{ID: kdfScrypt, First: 1 << 15, Second: 8, Third: 1}
+	defaultKDF = kdfParams{ID: kdfArgon2id, First: 3, Second: 64 * 1024, Third: 4}
+)
+
+// StoreInfo describes secrets.enc without decrypting it.
+type StoreInfo struct {
+	Path     string
+	Size     int64
+	Modified time.Time
+	// Version is 0 for the legacy headerless format.
+	Version   int
+	KDF       string
+	KDFParams string
+	Current   bool
+	// Recipients is set in recipient mode.
+	Recipients []Recipient
+}
+
+// Info reads the header of secrets.enc.
+func Info() (*StoreInfo, error) {
+	path, err := secretsPath()
+	if err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &StoreInfo{Path: path, Size: stat.Size(), Modified: stat.ModTime()}
+	if hasRecipientHeader(data) {
+		entries, _, err := parseRecipientHeader(data)
+		if err != nil {
+			return nil, err
+		}
+		info.Version = formatRecipientsVersion
+		info.KDF = "x25519"
+		info.KDFParams = fmt.Sprintf("%d recipient(s)", len(entries))
+		for _, entry := range entries {
+			info.Recipients = append(info.Recipients, entry.Recipient)
+		}
+		info.Current = true
+		return info, nil
+	}
+
+	params := legacyKDF
+	if hasFormatHeader(data) {
+		version, kdf, _, _, err := parseHeader(data)
+		if err != nil {
+			return nil, err
+		}
+		info.Version = version
+		params = kdf
+	}
+	info.KDF = params.name()
+	info.KDFParams = params.describe()
+	info.Current = info.Version == formatVersion && params == defaultKDF
+	return info, nil
+}
+
+func (p kdfParams) name() string {
+	switch p.ID {
+	case kdfScrypt:
+		return "scrypt"
+	case kdfArgon2id:
+		return "argon2id"
+	default:
+		return fmt.Sprintf("unknown (%d)", p.ID)
+	}
+}
+
+func (p kdfParams) describe() string {
+	switch p.ID {
+	case kdfScrypt:
+		return fmt.Sprintf("N=%d r=%d p=%d", p.First, p.Second, p.Third)
+	case kdfArgon2id:
+		return fmt.Sprintf("time=%d memory=%dKiB threads=%d", p.First, p.Second, p.Third)
+	default:
+		return "-"
+	}
+}
+
+// validate bounds parameters read from a file, so a crafted header cannot make
+// hbctl allocate unbounded memory or spin forever.
+func (p kdfParams) validate() error {
+	switch p.ID {
+	case kdfScrypt:
+		if p.First < 2 || p.First&(p.First-1) != 0 || p.First > 1<<22 || p.Second == 0 || p.Second > 32 || p.Third == 0 || p.Third > 16 {
+			return fmt.Errorf("unsupported scrypt parameters %s", p.describe())
+		}
+	case kdfArgon2id:
+		if p.First == 0 || p.First > 64 || p.Second < 8*p.Third || p.Second > 4*1024*1024 || p.Third == 0 || p.Third > 255 {
+			return fmt.Errorf("unsupported argon2id parameters %s", p.describe())
+		}
+	default:
+		return fmt.Errorf("unsupported key derivation %d", p.ID)
+	}
+	return nil
+}
+
+func (p kdfParams) deriveKey(pass string, salt []byte) ([]byte, error) {
+	switch p.ID {
+	case kdfScrypt:
+		return scrypt.Key([]byte(pass), salt, int(p.First), int(p.Second), int(p.Third), keySize)
+	case kdfArgon2id:
+		return argon2.IDKey([]byte(pass), salt, p.First, p.Second, uint8(p.Third), keySize), nil
+	default:
+		return nil, fmt.Errorf("unsupported key derivation %d", p.ID)
+	}
+}
+
+func hasFormatHeader(data []byte) bool {
+	return bytes.HasPrefix(data, formatMagic)
+}
+
+func marshalHeader(params kdfParams, salt []byte) []byte {
+	header := make([]byte, 0, headerFixedSize+len(salt))
+	header = append(header, formatMagic...)
+	header = append(header, formatVersion, params.ID)
+	header = binary.BigEndian.AppendUint32(header, params.First)
+	header = binary.BigEndian.AppendUint32(header, params.Second)
+	header = binary.BigEndian.AppendUint32(header, params.Third)
+	header = append(header, byte(len(salt)))
+	return append(header, salt...)
+}
+
+// parseHeader returns the format version, KDF parameters, salt, and header
+// length of a file that starts with formatMagic.
+func parseHeader(data []byte) (int, kdfParams, []byte, int, error) {
+	if len(data) < headerFixedSize {
+		return 0, kdfParams{}, nil, 0, errors.New("invalid encrypted file: truncated header")
+	}
+	version := int(data[4])
+	if version == formatRecipientsVersion {
+		return 0, kdfParams{}, nil, 0, errors.New("secrets.enc is encrypted to recipients, not a passphrase")
+	}
+	if version == 0 || version > formatRecipientsVersion {
+		return 0, kdfParams{}, nil, 0, fmt.Errorf("secrets file format version %d is not supported by this hbctl; upgrade hbctl", version)
+	}
+	params := kdfParams{
+		ID:     data[5],
+		First:  binary.BigEndian.Uint32(data[6:10]),
+		Second: binary.BigEndian.Uint32(data[10:14]),
+		Third:  binary.BigEndian.Uint32(data[14:18]),
+	}
+	if err := params.validate(); err != nil {
+		return 0, kdfParams{}, nil, 0, err
+	}
+	saltLen := int(data[18])
+	end := headerFixedSize + saltLen
+	if saltLen < saltSize || len(data) < end {
+		return 0, kdfParams{}, nil, 0, errors.New("invalid encrypted file: bad salt")
+	}
+	return version, params, data[headerFixedSize:end], end, nil
+}
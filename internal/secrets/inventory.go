@@ -0,0 +1,172 @@
+package secrets
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Entry names used by hbctl secrets list and the Store.Saved timestamps.
+const (
+	EntryMongoDB           = "mongodb"
+	EntryMongoRootPassword = "mongo-root-password"
+	EntryJWTSecret         = "jwtsecret"
+	EntryServiceKey        = "servicekey"
+	EntryAuthToken         = "auth-token"
+	EntryServer            = "server"
+)
+
+// StoreEntry describes one secrets.enc entry without its secret values.
+type StoreEntry struct {
+	Name    string     `json:"name"`
+	Present bool       `json:"present"`
+	Details []EntryKey `json:"details,omitempty"`
+	SavedAt string     `json:"saved_at,omitempty"`
+	// Fill is the hbctl command that stores a missing entry.
+	Fill string `json:"fill,omitempty"`
+}
+
+type EntryKey struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Inventory decrypts secrets.enc once and reports which entries are set,
+// with metadata such as hosts, key sizes, lengths, and hash prefixes. A
+// missing file reports every entry as missing without prompting.
+func Inventory() ([]StoreEntry, error) {
+	path, err := secretsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	store := &Store{}
+	if _, err := os.Stat(path); err == nil {
+		store, err = loadStore()
+		if err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	saved := func(name string) string {
+		return store.Saved[name]
+	}
+
+	entries := []StoreEntry{}
+
+	mongo := StoreEntry{Name: EntryMongoDB, Fill: "hbctl login mongodb --user <user> --password <password> --host <host>"}
+	if m := store.MongoDB; m != nil {
+		mongo.Present = true
+		mongo.SavedAt = saved(EntryMongoDB)
+		mongo.Details = []EntryKey{
+			{"host", m.Host},
+			{"port", fmt.Sprintf("%d", m.Port)},
+			{"user", m.User},
+			{"database", m.Database},
+		}
+		if m.AuthSource != "" {
+			mongo.Details = append(mongo.Details, EntryKey{"auth_source", m.AuthSource})
+		}
+		if m.ReplicaSet != "" {
+			mongo.Details = append(mongo.Details, EntryKey{"replica_set", m.ReplicaSet})
+		}
+	}
+	entries = append(entries, mongo)
+
+	root := StoreEntry{Name: EntryMongoRootPassword, Fill: "hbctl start (generated on first start)"}
+	if strings.TrimSpace(store.MongoRootPassword) != "" {
+		root.Present = true
+		root.SavedAt = saved(EntryMongoRootPassword)
+		root.Details = []EntryKey{{"length", fmt.Sprintf("%d", len(store.MongoRootPassword))}}
+	}
+	entries = append(entries, root)
+
+	jwt := StoreEntry{Name: EntryJWTSecret, Fill: "hbctl login jwtsecret --secret <secret>"}
+	if store.JWTSecret != nil && store.JWTSecret.JWTSecret != "" {
+		jwt.Present = true
+		jwt.SavedAt = saved(EntryJWTSecret)
+		jwt.Details = []EntryKey{
+			{"length", fmt.Sprintf("%d", len(store.JWTSecret.JWTSecret))},
+			{"sha256", hashPrefix(store.JWTSecret.JWTSecret)},
+		}
+	}
+	entries = append(entries, jwt)
+
+	key := StoreEntry{Name: EntryServiceKey, Fill: "hbctl login servicekey --generate"}
+	if store.ServiceKey != nil && (store.ServiceKey.PubSvcKey != "" || store.ServiceKey.PrivSvcKey != "") {
+		key.Present = true
+		key.SavedAt = saved(EntryServiceKey)
+		key.Details = serviceKeyDetails(store.ServiceKey)
+	}
+	entries = append(entries, key)
+
+	// Login tokens moved to the session file; older builds kept them here.
+	token := StoreEntry{Name: EntryAuthToken, Fill: "hbctl login -u <email> -p <password>"}
+	source := "secrets.enc"
+	tok := store.AuthToken
+	if tok == nil || strings.TrimSpace(tok.AccessToken) == "" {
+		tok, _ = LoadAuthSession()
+		source, _ = SessionPath()
+	}
+	if tok != nil && strings.TrimSpace(tok.AccessToken) != "" {
+		token.Present = true
+		token.SavedAt = tok.SavedAt
+		token.Details = []EntryKey{{"email", tok.Email}, {"source", source}, {"sha256", hashPrefix(tok.AccessToken)}}
+	}
+	entries = append(entries, token)
+
+	server := StoreEntry{Name: EntryServer, Fill: "hbctl server set <url>"}
+	if store.Server != nil && strings.TrimSpace(store.Server.BaseURL) != "" {
+		server.Present = true
+		server.SavedAt = store.Server.SavedAt
+		if server.SavedAt == "" {
+			server.SavedAt = saved(EntryServer)
+		}
+		server.Details = []EntryKey{{"url", store.Server.BaseURL}}
+	}
+	entries = append(entries, server)
+
+	for i := range entries {
+		if entries[i].Present {
+			entries[i].Fill = ""
+		}
+	}
+	return entries, nil
+}
+
+func serviceKeyDetails(key *ServiceKey) []EntryKey {
+	details := []EntryKey{}
+	if block, _ := pem.Decode([]byte(key.PubSvcKey)); block != nil {
+		if parsed, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+			if rsaKey, ok := parsed.(*rsa.PublicKey); ok {
+				details = append(details, EntryKey{"rsa_bits", fmt.Sprintf("%d", rsaKey.N.BitLen())})
+			}
+			sum := sha256.Sum256(block.Bytes)
+			details = append(details, EntryKey{"fingerprint", "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])})
+		} else {
+			details = append(details, EntryKey{"public_key", "invalid"})
+		}
+	} else {
+		details = append(details, EntryKey{"public_key", "missing"})
+	}
+	private := "valid"
+	if key.PrivSvcKey == "" {
+		private = "missing"
+	} else if ValidateServicePrivateKey(key.PrivSvcKey) != nil {
+		private = "invalid"
+	}
+	return append(details, EntryKey{"private_key", private})
+}
+
+// hashPrefix identifies a secret without revealing it.
+func hashPrefix(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])[:12]
+}
@@ -0,0 +1,540 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// In recipient mode secrets.enc is encrypted with a random data key, and the
+// header carries that key wrapped for each recipient's X25519 public key:
+//
+//	magic "HBSE" | version 2 | count u8 |
+//	count × (public key 32 | ephemeral key 32 | wrapped key 48 | label length u8 | label) |
+//	nonce | AES-256-GCM ciphertext
+//
+// Each wrapping key is HKDF-SHA256 over the X25519 shared secret of a fresh
+// ephemeral key and the recipient, so no two wraps share a key. The whole
+// header is authenticated with the ciphertext.
+const (
+	formatRecipientsVersion = 2
+
+	publicKeyPrefix  = "hbx25519:"
+	identityPrefix   = "HBCTL-X25519-SECRET-KEY:"
+	identityFileName = "identity"
+	recipientHKDF    = "hbctl secrets recipient v1"
+
+	wrappedKeySize = keySize + 16
+	maxRecipients  = 255
+	maxLabelSize   = 255
+)
+
+// Recipient is a public key secrets.enc is encrypted to.
+type Recipient struct {
+	PublicKey string `json:"public_key"`
+	Label     string `json:"label,omitempty"`
+}
+
+type identity struct {
+	private *ecdh.PrivateKey
+	path    string
+}
+
+func (id *identity) publicKey() string {
+	return encodePublicKey(id.private.PublicKey().Bytes())
+}
+
+// storeKey opens and seals secrets.enc: with the passphrase, or in recipient
+// mode with the identity file and the current recipient list.
+type storeKey struct {
+	pass       string
+	identity   *identity
+	recipients []Recipient
+}
+
+// unlockStore returns the key for the current secrets.enc, prompting for the
+// passphrase only when the file is not in recipient mode.
+func unlockStore(confirm bool) (*storeKey, error) {
+	recipients, ok, err := currentRecipients()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		pass, err := getPassphrase(confirm)
+		if err != nil {
+			return nil, err
+		}
+		return &storeKey{pass: pass}, nil
+	}
+
+	id, err := loadIdentity()
+	if err != nil {
+		return nil, fmt.Errorf("secrets.enc is encrypted to recipients: %w", err)
+	}
+	mine := id.publicKey()
+	for _, recipient := range recipients {
+		if recipient.PublicKey == mine {
+			return &storeKey{identity: id, recipients: recipients}, nil
+		}
+	}
+	return nil, fmt.Errorf("your identity %s is not a recipient of secrets.enc; ask a recipient to run hbctl secrets recipients add %s", mine, mine)
+}
+
+func (k *storeKey) open(data []byte) ([]byte, error) {
+	if hasRecipientHeader(data) {
+		if k.identity == nil {
+			return nil, errors.New("secrets.enc is encrypted to recipients")
+		}
+		return decryptForIdentity(data, k.identity)
+	}
+	return decrypt(data, k.pass)
+}
+
+func (k *storeKey) seal(plain []byte) ([]byte, error) {
+	if k.identity != nil {
+		return encryptToRecipients(plain, k.recipients)
+	}
+	return encrypt(plain, k.pass)
+}
+
+// IdentityPath returns HBCTL_IDENTITY or ~/.hbctl/identity. The identity is
+// personal, so it stays in the home directory even when --secrets points at
+// a shared store.
+func IdentityPath() (string, error) {
+	if v := strings.TrimSpace(os.Getenv("HBCTL_IDENTITY")); v != "" {
+		return filepath.Abs(v)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".hbctl", identityFileName), nil
+}
+
+// EnsureIdentity returns the public key of the identity file, creating the
+// file first when it does not exist.
+func EnsureIdentity() (string, string, bool, error) {
+	path, err := IdentityPath()
+	if err != nil {
+		return "", "", false, err
+	}
+	if id, err := loadIdentity(); err == nil {
+		return id.publicKey(), path, false, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", "", false, err
+	}
+
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", false, err
+	}
+	public := encodePublicKey(private.PublicKey().Bytes())
+	content := fmt.Sprintf("# hbctl identity created %s\n# public key: %s\n%s%s\n",
+		time.Now().UTC().Format(time.RFC3339), public, identityPrefix, base64.RawURLEncoding.EncodeToString(private.Bytes()))
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", "", false, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", "", false, err
+	}
+	if _, err := file.WriteString(content); err != nil {
+		_ = file.Close()
+		return "", "", false, err
+	}
+	return public, path, true, file.Close()
+}
+
+func loadIdentity() (*identity, error) {
+	path, err := IdentityPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("no identity at %s; create one with hbctl secrets identity: %w", path, os.ErrNotExist)
+		}
+		return nil, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		encoded, ok := strings.CutPrefix(strings.TrimSpace(line), identityPrefix)
+		if !ok {
+			continue
+		}
+		raw, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity file %s", path)
+		}
+		private, err := ecdh.X25519().NewPrivateKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity file %s", path)
+		}
+		return &identity{private: private, path: path}, nil
+	}
+	return nil, fmt.Errorf("invalid identity file %s: no %s line", path, strings.TrimSuffix(identityPrefix, ":"))
+}
+
+// ListRecipients reads the recipients from the secrets.enc header without
+// decrypting it. ok is false for a passphrase-protected store.
+func ListRecipients() ([]Recipient, bool, error) {
+	return currentRecipients()
+}
+
+// IdentityPublicKey returns the public key of the identity file, or "" when
+// there is none.
+func IdentityPublicKey() string {
+	id, err := loadIdentity()
+	if err != nil {
+		return ""
+	}
+	return id.publicKey()
+}
+
+// AddRecipient encrypts secrets.enc to one more public key. A passphrase
+// store is switched to recipient mode, encrypted to your identity (created if
+// needed) and the new key. The previous file is kept as
+// secrets.enc.<timestamp>.bak.
+func AddRecipient(publicKey string, label string) (string, error) {
+	publicKey = strings.TrimSpace(publicKey)
+	if _, err := decodePublicKey(publicKey); err != nil {
+		return "", err
+	}
+	if len(label) > maxLabelSize {
+		return "", fmt.Errorf("label is longer than %d bytes", maxLabelSize)
+	}
+	return rewrapStore(func(key *storeKey) ([]Recipient, error) {
+		recipients := append([]Recipient{}, key.recipients...)
+		if key.identity == nil {
+			public, _, _, err := EnsureIdentity()
+			if err != nil {
+				return nil, err
+			}
+			recipients = []Recipient{{PublicKey: public, Label: defaultRecipientLabel()}}
+		}
+		for _, recipient := range recipients {
+			if recipient.PublicKey == publicKey {
+				return nil, fmt.Errorf("%s is already a recipient", publicKey)
+			}
+		}
+		if len(recipients) >= maxRecipients {
+			return nil, fmt.Errorf("secrets.enc already has %d recipients", maxRecipients)
+		}
+		return append(recipients, Recipient{PublicKey: publicKey, Label: strings.TrimSpace(label)}), nil
+	})
+}
+
+// RemoveRecipient drops a recipient, matched by public key or label, and
+// re-encrypts under a new data key so the removed key cannot open later
+// saves. Removing your own identity or the last recipient is refused.
+func RemoveRecipient(match string) (string, error) {
+	match = strings.TrimSpace(match)
+	return rewrapStore(func(key *storeKey) ([]Recipient, error) {
+		if key.identity == nil {
+			return nil, errors.New("secrets.enc is protected by a passphrase, not recipients")
+		}
+		kept := []Recipient{}
+		removed := 0
+		for _, recipient := range key.recipients {
+			if recipient.PublicKey == match || (recipient.Label != "" && recipient.Label == match) {
+				if recipient.PublicKey == key.identity.publicKey() {
+					return nil, errors.New("refusing to remove your own identity; another recipient can remove it")
+				}
+				removed++
+				continue
+			}
+			kept = append(kept, recipient)
+		}
+		if removed == 0 {
+			return nil, fmt.Errorf("no recipient matches %q", match)
+		}
+		if removed > 1 {
+			return nil, fmt.Errorf("%q matches %d recipients; remove by public key", match, removed)
+		}
+		return kept, nil
+	})
+}
+
+// DisableRecipients switches secrets.enc back to passphrase mode, using
+// HBCTL_PASSPHRASE or a confirmed prompt.
+func DisableRecipients() (string, error) {
+	return rewrapStore(func(key *storeKey) ([]Recipient, error) {
+		if key.identity == nil {
+			return nil, errors.New("secrets.enc is already protected by a passphrase")
+		}
+		return nil, nil
+	})
+}
+
+// rewrapStore decrypts secrets.enc and writes it back sealed for the
+// recipients update returns, or with a passphrase when it returns none.
+func rewrapStore(update func(*storeKey) ([]Recipient, error)) (string, error) {
+	path, err := secretsPath()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("no encrypted secrets at %s", path)
+		}
+		return "", err
+	}
+	key, err := unlockStore(false)
+	if err != nil {
+		return "", err
+	}
+	plain, err := key.open(data)
+	if err != nil {
+		return "", errors.New("failed to decrypt secrets (wrong passphrase or identity?)")
+	}
+	recipients, err := update(key)
+	if err != nil {
+		return "", err
+	}
+
+	next := &storeKey{recipients: recipients}
+	if len(recipients) == 0 {
+		cachedPassphrase = ""
+		if next.pass, err = getPassphrase(true); err != nil {
+			return "", err
+		}
+	} else if next.identity, err = loadIdentity(); err != nil {
+		return "", err
+	}
+	enc, err := next.seal(plain)
+	if err != nil {
+		return "", err
+	}
+	if check, err := next.open(enc); err != nil || !bytes.Equal(check, plain) {
+		return "", errors.New("re-encrypted secrets did not decrypt; secrets.enc was not changed")
+	}
+
+	backup := fmt.Sprintf("%s.%s.bak", path, time.Now().UTC().Format("20060102T150405Z"))
+	if err := os.WriteFile(backup, data, 0600); err != nil {
+		return "", err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, enc, 0600); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	return backup, nil
+}
+
+func currentRecipients() ([]Recipient, bool, error) {
+	path, err := secretsPath()
+	if err != nil {
+		return nil, false, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	if !hasRecipientHeader(data) {
+		return nil, false, nil
+	}
+	entries, _, err := parseRecipientHeader(data)
+	if err != nil {
+		return nil, false, err
+	}
+	recipients := make([]Recipient, 0, len(entries))
+	for _, entry := range entries {
+		recipients = append(recipients, entry.Recipient)
+	}
+	return recipients, true, nil
+}
+
+type wrappedRecipient struct {
+	Recipient
+	public    []byte
+	ephemeral []byte
+	wrapped   []byte
+}
+
+func hasRecipientHeader(data []byte) bool {
+	return hasFormatHeader(data) && len(data) > 4 && data[4] == formatRecipientsVersion
+}
+
+func encryptToRecipients(plain []byte, recipients []Recipient) ([]byte, error) {
+	if len(recipients) == 0 || len(recipients) > maxRecipients {
+		return nil, fmt.Errorf("secrets.enc needs between 1 and %d recipients", maxRecipients)
+	}
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	header := append([]byte{}, formatMagic...)
+	header = append(header, formatRecipientsVersion, byte(len(recipients)))
+	for _, recipient := range recipients {
+		public, err := decodePublicKey(recipient.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		shared, err := ephemeral.ECDH(public)
+		if err != nil {
+			return nil, err
+		}
+		gcm, err := recipientWrapper(shared, ephemeral.PublicKey().Bytes(), public.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		// The wrapping key is used once, so a zero nonce is safe.
+		wrapped := gcm.Seal(nil, make([]byte, gcm.NonceSize()), dataKey, nil)
+		header = append(header, public.Bytes()...)
+		header = append(header, ephemeral.PublicKey().Bytes()...)
+		header = append(header, wrapped...)
+		header = append(header, byte(len(recipient.Label)))
+		header = append(header, recipient.Label...)
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	out := append(append([]byte{}, header...), nonce...)
+	return gcm.Seal(out, nonce, plain, header), nil
+}
+
+func decryptForIdentity(data []byte, id *identity) ([]byte, error) {
+	entries, headerLen, err := parseRecipientHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	mine := id.private.PublicKey().Bytes()
+	for _, entry := range entries {
+		if !bytes.Equal(entry.public, mine) {
+			continue
+		}
+		ephemeral, err := ecdh.X25519().NewPublicKey(entry.ephemeral)
+		if err != nil {
+			return nil, err
+		}
+		shared, err := id.private.ECDH(ephemeral)
+		if err != nil {
+			return nil, err
+		}
+		wrapper, err := recipientWrapper(shared, entry.ephemeral, entry.public)
+		if err != nil {
+			return nil, err
+		}
+		dataKey, err := wrapper.Open(nil, make([]byte, wrapper.NonceSize()), entry.wrapped, nil)
+		if err != nil {
+			return nil, err
+		}
+		gcm, err := newGCM(dataKey)
+		if err != nil {
+			return nil, err
+		}
+		rest := data[headerLen:]
+		if len(rest) < gcm.NonceSize() {
+			return nil, errors.New("invalid encrypted payload")
+		}
+		return gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], data[:headerLen])
+	}
+	return nil, fmt.Errorf("identity %s is not a recipient", id.publicKey())
+}
+
+func parseRecipientHeader(data []byte) ([]wrappedRecipient, int, error) {
+	if len(data) < 6 {
+		return nil, 0, errors.New("invalid encrypted file: truncated header")
+	}
+	count := int(data[5])
+	offset := 6
+	entries := make([]wrappedRecipient, 0, count)
+	for i := 0; i < count; i++ {
+		if len(data) < offset+32+32+wrappedKeySize+1 {
+			return nil, 0, errors.New("invalid encrypted file: truncated recipient")
+		}
+		entry := wrappedRecipient{
+			public:    data[offset : offset+32],
+			ephemeral: data[offset+32 : offset+64],
+			wrapped:   data[offset+64 : offset+64+wrappedKeySize],
+		}
+		offset += 64 + wrappedKeySize
+		labelLen := int(data[offset])
+		offset++
+		if len(data) < offset+labelLen {
+			return nil, 0, errors.New("invalid encrypted file: truncated recipient label")
+		}
+		entry.Recipient = Recipient{PublicKey: encodePublicKey(entry.public), Label: string(data[offset : offset+labelLen])}
+		offset += labelLen
+		entries = append(entries, entry)
+	}
+	if count == 0 {
+		return nil, 0, errors.New("invalid encrypted file: no recipients")
+	}
+	return entries, offset, nil
+}
+
+func recipientWrapper(shared []byte, ephemeral []byte, public []byte) (cipher.AEAD, error) {
+	salt := append(append([]byte{}, ephemeral...), public...)
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(recipientHKDF)), key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encodePublicKey(raw []byte) string {
+	return publicKeyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodePublicKey(value string) (*ecdh.PublicKey, error) {
+	encoded, ok := strings.CutPrefix(strings.TrimSpace(value), publicKeyPrefix)
+	if !ok {
+		return nil, fmt.Errorf("invalid public key %q: expected %s...", value, publicKeyPrefix)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(raw) != 32 {
+		return nil, fmt.Errorf("invalid public key %q", value)
+	}
+	return ecdh.X25519().NewPublicKey(raw)
+}
+
+func defaultRecipientLabel() string {
+	name := "hbctl"
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		name = current.Username
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		name += "@" + host
+	}
+	if len(name) > maxLabelSize {
+		name = name[:maxLabelSize]
+	}
+	return name
+}
@@ -0,0 +1,124 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// rekeyBackupPattern matches the backups Rekey leaves next to secrets.enc.
+const rekeyBackupPattern = "secrets.enc.*.bak"
+
+// Rekey re-encrypts secrets.enc under a new passphrase. The old passphrase
+// comes from HBCTL_PASSPHRASE or a prompt; the new one from
+// HBCTL_NEW_PASSPHRASE or a confirmed prompt. The previous file is kept as
+// secrets.enc.<timestamp>.bak, whose path is returned, until ConfirmRekey
+// removes it.
+func Rekey() (string, error) {
+	path, err := secretsPath()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("no encrypted secrets at %s", path)
+		}
+		return "", err
+	}
+	if hasRecipientHeader(data) {
+		return "", errors.New("secrets.enc is encrypted to recipients; manage access with hbctl secrets recipients")
+	}
+
+	oldPass, err := getPassphrase(false)
+	if err != nil {
+		return "", err
+	}
+	plain, err := decrypt(data, oldPass)
+	if err != nil {
+		return "", errors.New("failed to decrypt secrets (wrong passphrase?)")
+	}
+
+	fmt.Println("Choose the new hbctl passphrase.")
+	newPass, err := newPassphrase()
+	if err != nil {
+		return "", err
+	}
+	if newPass == oldPass {
+		return "", errors.New("the new passphrase is the same as the current one")
+	}
+
+	enc, err := encrypt(plain, newPass)
+	if err != nil {
+		return "", err
+	}
+	if check, err := decrypt(enc, newPass); err != nil || !bytes.Equal(check, plain) {
+		return "", errors.New("re-encrypted secrets did not decrypt with the new passphrase; secrets.enc was not changed")
+	}
+
+	backup := fmt.Sprintf("%s.%s.bak", path, time.Now().UTC().Format("20060102T150405Z"))
+	if err := os.WriteFile(backup, data, 0600); err != nil {
+		return "", err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, enc, 0600); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+
+	cachedPassphrase = newPass
+	return backup, nil
+}
+
+// ConfirmRekey checks that secrets.enc opens with the current passphrase or
+// identity and then removes the backups left by Rekey or by recipient changes,
+// returning their paths.
+func ConfirmRekey() ([]string, error) {
+	path, err := secretsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := unlockStore(false)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := key.open(data); err != nil {
+		return nil, errors.New("failed to decrypt secrets (wrong passphrase or identity?); backups were kept")
+	}
+
+	backups, err := filepath.Glob(filepath.Join(filepath.Dir(path), rekeyBackupPattern))
+	if err != nil {
+		return nil, err
+	}
+	for _, backup := range backups {
+		if err := os.Remove(backup); err != nil {
+			return nil, err
+		}
+	}
+	return backups, nil
+}
+
+// newPassphrase asks for the passphrase Rekey encrypts with. getPassphrase
+// would return the old one from its cache or HBCTL_PASSPHRASE, so both are
+// set aside while it prompts.
+func newPassphrase() (string, error) {
+	if v := os.Getenv("HBCTL_NEW_PASSPHRASE"); v != "" {
+		return v, nil
+	}
+	cachedPassphrase = ""
+	if old, ok := os.LookupEnv("HBCTL_PASSPHRASE"); ok {
+		_ = os.Unsetenv("HBCTL_PASSPHRASE")
+		defer os.Setenv("HBCTL_PASSPHRASE", old)
+	}
+	return getPassphrase(true)
+}
@@ -12,8 +12,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
-	"golang.org/x/crypto/scrypt"
 	"golang.org/x/term"
 )
 
@@ -53,6 +53,43 @@ func secretsPath() (string, error) {
 	return filepath.Join(dir, "secrets.enc"), nil
 }
 
+// loadStore decrypts secrets.enc once and returns its contents.
+func loadStore() (*Store, error) {
+	path, err := secretsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := unlockStore(false)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := key.open(data)
+	if err != nil {
+		return nil, errors.New("failed to decrypt secrets (wrong passphrase?)")
+	}
+
+	var store Store
+	if err := json.Unmarshal(plain, &store); err != nil {
+		return nil, err
+	}
+	return &store, nil
+}
+
+// markSaved stamps entry with the current time for hbctl secrets list.
+func (s *Store) markSaved(entry string) {
+	if s.Saved == nil {
+		s.Saved = map[string]string{}
+	}
+	s.Saved[entry] = time.Now().UTC().Format(time.RFC3339)
+}
+
 func SaveMongo(secret *MongoSecret) error {
 	path, err := secretsPath()
 	if err != nil {
@@ -69,7 +106,7 @@ func SaveMongo(secret *MongoSecret) error {
 		firstTime = true
 	}
 
-	pass, err := getPassphrase(firstTime)
+	key, err := unlockStore(firstTime)
 	if err != nil {
 		return err
 	}
@@ -81,7 +118,7 @@ func SaveMongo(secret *MongoSecret) error {
 		if err != nil {
 			return err
 		}
-		plain, err := decrypt(data, pass)
+		plain, err := key.open(data)
 		if err != nil {
 			return errors.New("failed to decrypt secrets (wrong passphrase?)")
 		}
@@ -89,13 +126,14 @@ func SaveMongo(secret *MongoSecret) error {
 	}
 
 	store.MongoDB = secret
+	store.markSaved(EntryMongoDB)
 
 	plain, err := json.MarshalIndent(store, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	enc, err := encrypt(plain, pass)
+	enc, err := key.seal(plain)
 	if err != nil {
 		return err
 	}
@@ -123,7 +161,7 @@ func SaveJWTSecret(secret *JWTSecret) error {
 		firstTime = true
 	}
 
-	pass, err := getPassphrase(firstTime)
+	key, err := unlockStore(firstTime)
 	if err != nil {
 		return err
 	}
@@ -135,7 +173,7 @@ func SaveJWTSecret(secret *JWTSecret) error {
 		if err != nil {
 			return err
 		}
-		plain, err := decrypt(data, pass)
+		plain, err := key.open(data)
 		if err != nil {
 			return errors.New("failed to decrypt secrets (wrong passphrase?)")
 		}
@@ -143,13 +181,14 @@ func SaveJWTSecret(secret *JWTSecret) error {
 	}
 
 	store.JWTSecret = secret
+	store.markSaved(EntryJWTSecret)
 
 	plain, err := json.MarshalIndent(store, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	enc, err := encrypt(plain, pass)
+	enc, err := key.seal(plain)
 	if err != nil {
 		return err
 	}
@@ -172,12 +211,12 @@ func LoadMongo() (*MongoSecret, error) {
 		return nil, err
 	}
 
-	pass, err := getPassphrase(false)
+	key, err := unlockStore(false)
 	if err != nil {
 		return nil, err
 	}
 
-	plain, err := decrypt(data, pass)
+	plain, err := key.open(data)
 	if err != nil {
 		return nil, errors.New("failed to decrypt secrets (wrong passphrase?)")
 	}
@@ -205,12 +244,12 @@ func LoadJWTSecret() (*JWTSecret, error) {
 		return nil, err
 	}
 
-	pass, err := getPassphrase(false)
+	key, err := unlockStore(false)
 	if err != nil {
 		return nil, err
 	}
 
-	plain, err := decrypt(data, pass)
+	plain, err := key.open(data)
 	if err != nil {
 		return nil, errors.New("failed to decrypt secrets (wrong passphrase?)")
 	}
@@ -239,7 +278,19 @@ func getPassphrase(confirm bool) (string, error) {
 		return v, nil
 	}
 
-	fmt.Print("Enter hbctl passphrase: ")
+	pass, err := promptPassphrase("hbctl passphrase", confirm)
+	if err != nil {
+		return "", err
+	}
+
+	cachedPassphrase = pass
+	return cachedPassphrase, nil
+}
+
+// promptPassphrase reads a passphrase from the terminal, asking twice when
+// confirm is set.
+func promptPassphrase(label string, confirm bool) (string, error) {
+	fmt.Printf("Enter %s: ", label)
 	p1, err := term.ReadPassword(int(os.Stdin.Fd()))
 	fmt.Println()
 	if err != nil {
@@ -250,7 +301,7 @@ func getPassphrase(confirm bool) (string, error) {
 	}
 
 	if confirm {
-		fmt.Print("Confirm hbctl passphrase: ")
+		fmt.Printf("Confirm %s: ", label)
 		p2, err := term.ReadPassword(int(os.Stdin.Fd()))
 		fmt.Println()
 		if err != nil {
@@ -261,8 +312,7 @@ func getPassphrase(confirm bool) (string, error) {
 		}
 	}
 
-	cachedPassphrase = string(p1)
-	return cachedPassphrase, nil
+	return string(p1), nil
 }
 
 func SaveAuthToken(secret *AuthToken) error {
@@ -295,12 +345,12 @@ func LoadAuthToken() (*AuthToken, error) {
 		return nil, err
 	}
 
-	pass, err := getPassphrase(false)
+	key, err := unlockStore(false)
 	if err != nil {
 		return nil, err
 	}
 
-	plain, err := decrypt(data, pass)
+	plain, err := key.open(data)
 	if err != nil {
 		return nil, errors.New("failed to decrypt secrets (wrong passphrase?)")
 	}
@@ -336,7 +386,7 @@ func SaveServiceKey(secret *ServiceKey) error {
 		firstTime = true
 	}
 
-	pass, err := getPassphrase(firstTime)
+	key, err := unlockStore(firstTime)
 	if err != nil {
 		return err
 	}
@@ -348,7 +398,7 @@ func SaveServiceKey(secret *ServiceKey) error {
 		if err != nil {
 			return err
 		}
-		plain, err := decrypt(data, pass)
+		plain, err := key.open(data)
 		if err != nil {
 			return errors.New("failed to decrypt secrets (wrong passphrase?)")
 		}
@@ -356,13 +406,14 @@ func SaveServiceKey(secret *ServiceKey) error {
 	}
 
 	store.ServiceKey = secret
+	store.markSaved(EntryServiceKey)
 
 	plain, err := json.MarshalIndent(store, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	enc, err := encrypt(plain, pass)
+	enc, err := key.seal(plain)
 	if err != nil {
 		return err
 	}
@@ -385,12 +436,12 @@ func LoadServiceKey() (*ServiceKey, error) {
 		return nil, err
 	}
 
-	pass, err := getPassphrase(false)
+	key, err := unlockStore(false)
 	if err != nil {
 		return nil, err
 	}
 
-	plain, err := decrypt(data, pass)
+	plain, err := key.open(data)
 	if err != nil {
 		return nil, errors.New("failed to decrypt secrets (wrong passphrase?)")
 	}
@@ -423,7 +474,7 @@ func EnsureMongoRootPassword() (string, error) {
 		firstTime = true
 	}
 
-	pass, err := getPassphrase(firstTime)
+	key, err := unlockStore(firstTime)
 	if err != nil {
 		return "", err
 	}
@@ -434,7 +485,7 @@ func EnsureMongoRootPassword() (string, error) {
 		if err != nil {
 			return "", err
 		}
-		plain, err := decrypt(data, pass)
+		plain, err := key.open(data)
 		if err != nil {
 			return "", errors.New("failed to decrypt secrets (wrong passphrase?)")
 		}
@@ -452,13 +503,14 @@ func EnsureMongoRootPassword() (string, error) {
 		return "", err
 	}
 	store.MongoRootPassword = rootPass
+	store.markSaved(EntryMongoRootPassword)
 
 	plain, err := json.MarshalIndent(store, "", "  ")
 	if err != nil {
 		return "", err
 	}
 
-	enc, err := encrypt(plain, pass)
+	enc, err := key.seal(plain)
 	if err != nil {
 		return "", err
 	}
@@ -493,34 +545,60 @@ func encrypt(data []byte, pass string) ([]byte, error) {
 		return nil, err
 	}
 
-	key, err := scrypt.Key([]byte(pass), salt, 1<<15, 8, 1, keySize)
+	key, err := defaultKDF.deriveKey(pass, salt)
 	if err != nil {
 		return nil, err
 	}
 
-	block, err := aes.NewCipher(key)
+	gcm, err := newGCM(key)
 	if err != nil {
 		return nil, err
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	header := marshalHeader(defaultKDF, salt)
+	out := append(header, nonce...)
+	return gcm.Seal(out, nonce, data, header), nil
+}
+
+func decrypt(data []byte, pass string) ([]byte, error) {
+	if !hasFormatHeader(data) {
+		return decryptLegacy(data, pass)
+	}
+
+	_, params, salt, headerLen, err := parseHeader(data)
 	if err != nil {
 		return nil, err
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+	key, err := params.deriveKey(pass, salt)
+	if err != nil {
 		return nil, err
 	}
 
-	ciphertext := gcm.Seal(nil, nonce, data, nil)
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
 
-	out := append(salt, nonce...)
-	out = append(out, ciphertext...)
-	return out, nil
+	rest := data[headerLen:]
+	if len(rest) < gcm.NonceSize() {
+		return nil, errors.New("invalid encrypted payload")
+	}
+
+	nonce := rest[:gcm.NonceSize()]
+	ciphertext := rest[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, ciphertext, data[:headerLen])
 }
 
-func decrypt(data []byte, pass string) ([]byte, error) {
+// decryptLegacy opens files written before the format header, which are
+// salt || nonce || ciphertext under legacyKDF.
+func decryptLegacy(data []byte, pass string) ([]byte, error) {
 	if len(data) < saltSize {
 		return nil, errors.New("invalid encrypted file")
 	}
@@ -528,17 +606,12 @@ func decrypt(data []byte, pass string) ([]byte, error) {
 	salt := data[:saltSize]
 	rest := data[saltSize:]
 
-	key, err := scrypt.Key([]byte(pass), salt, 1<<15, 8, 1, keySize)
+	key, err := legacyKDF.deriveKey(pass, salt)
 	if err != nil {
 		return nil, err
 	}
 
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
-
-	gcm, err := cipher.NewGCM(block)
+	gcm, err := newGCM(key)
 	if err != nil {
 		return nil, err
 	}
@@ -553,6 +626,14 @@ func decrypt(data []byte, pass string) ([]byte, error) {
 	return gcm.Open(nil, nonce, ciphertext, nil)
 }
 
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
 func SaveServerConfig(config *ServerConfig) error {
 	path, err := secretsPath()
 	if err != nil {
@@ -569,7 +650,7 @@ func SaveServerConfig(config *ServerConfig) error {
 		firstTime = true
 	}
 
-	pass, err := getPassphrase(firstTime)
+	key, err := unlockStore(firstTime)
 	if err != nil {
 		return err
 	}
@@ -580,7 +661,7 @@ func SaveServerConfig(config *ServerConfig) error {
 		if err != nil {
 			return err
 		}
-		plain, err := decrypt(data, pass)
+		plain, err := key.open(data)
 		if err != nil {
 			return errors.New("failed to decrypt secrets (wrong passphrase?)")
 		}
@@ -588,13 +669,14 @@ func SaveServerConfig(config *ServerConfig) error {
 	}
 
 	store.Server = config
+	store.markSaved(EntryServer)
 
 	plain, err := json.MarshalIndent(store, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	enc, err := encrypt(plain, pass)
+	enc, err := key.seal(plain)
 	if err != nil {
 		return err
 	}
@@ -617,12 +699,12 @@ func LoadServerConfig() (*ServerConfig, error) {
 		return nil, err
 	}
 
-	pass, err := getPassphrase(false)
+	key, err := unlockStore(false)
 	if err != nil {
 		return nil, err
 	}
 
-	plain, err := decrypt(data, pass)
+	plain, err := key.open(data)
 	if err != nil {
 		return nil, errors.New("failed to decrypt secrets (wrong passphrase?)")
 	}
@@ -650,12 +732,12 @@ func ClearServerConfig() error {
 		return err
 	}
 
-	pass, err := getPassphrase(false)
+	key, err := unlockStore(false)
 	if err != nil {
 		return err
 	}
 
-	plain, err := decrypt(data, pass)
+	plain, err := key.open(data)
 	if err != nil {
 		return errors.New("failed to decrypt secrets (wrong passphrase?)")
 	}
@@ -672,7 +754,7 @@ func ClearServerConfig() error {
 		return err
 	}
 
-	enc, err := encrypt(plain, pass)
+	enc, err := key.seal(plain)
 	if err != nil {
 		return err
 	}
@@ -40,4 +40,6 @@ type Store struct {
 	ServiceKey        *ServiceKey   `json:"servicekey,omitempty"`
 	AuthToken         *AuthToken    `json:"auth_token,omitempty"`
 	Server            *ServerConfig `json:"server,omitempty"`
+	// Saved records when each entry was last written, keyed by entry name.
+	Saved map[string]string `json:"saved,omitempty"`
 }
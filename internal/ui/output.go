@@ -114,7 +114,7 @@ func FKeyValues(w io.Writer, values [][2]string) {
 func Plan(title string, items []string) { FPlan(os.Stdout, title, items) }
 
 func FPlan(w io.Writer, title string, items []string) {
-	Section(title)
+	FSection(w, title)
 	for i, item := range items {
 		fmt.Fprintf(w, "  %s %s\n", color(cyan, fmt.Sprintf("%2d.", i+1)), item)
 	}
@@ -213,3 +213,18 @@ func padRight(value string, width int) string {
 	}
 	return value + strings.Repeat(" ", padding)
 }
+
+// Size formats a byte count with binary units, such as "1.5 GiB".
+func Size(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	const prefixes = "KMGTPE"
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit && exp < len(prefixes)-1; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), prefixes[exp])
+}
@@ -63,3 +63,36 @@ var ServiceUnits = map[string][]string{
 	"proxy":             {"herringbone-proxy"},
 	"llm":               {"ollama"},
 }
+
+// ElementDependencies lists the elements each element needs running before it
+// starts. Protected core (mongodb, herringbone-proxy, herringbone-auth) is
+// listed explicitly so dependency ordering matches the full-stack start policy.
+var ElementDependencies = map[string][]string{
+	"mongodb":           nil,
+	"herringbone-proxy": nil,
+	"herringbone-auth":  {"mongodb", "herringbone-proxy"},
+
+	"logingestion-receiver": {"mongodb"},
+
+	"herringbone-logs":   {"mongodb", "herringbone-auth"},
+	"herringbone-search": {"mongodb", "herringbone-auth"},
+
+	"fingerprint-scoreset":   {"mongodb", "herringbone-auth"},
+	"fingerprint-identifier": {"fingerprint-scoreset"},
+	"ollama":                 nil,
+	"fingerprint-tuner":      {"ollama", "fingerprint-scoreset"},
+
+	"parser-cardset":    {"mongodb", "herringbone-auth"},
+	"parser-enrichment": {"fingerprint-identifier", "fingerprint-scoreset"},
+	"parser-extractor":  {"mongodb", "herringbone-auth"},
+
+	"detectionengine-detector": {"mongodb", "herringbone-auth"},
+	"detectionengine-matcher":  {"mongodb", "herringbone-auth"},
+	"detectionengine-ruleset":  {"mongodb", "herringbone-auth"},
+
+	"incidents-incidentset":  {"mongodb", "herringbone-auth"},
+	"incidents-correlator":   {"mongodb", "herringbone-auth"},
+	"incidents-orchestrator": {"mongodb", "herringbone-auth"},
+
+	"operations-center": {"herringbone-proxy"},
+}
@@ -0,0 +1,293 @@
+package units
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CatalogFileName is the manifest a Herringbone checkout ships next to its
+// compose files. When present it replaces the built-in tables in this package.
+const CatalogFileName = "hbctl-catalog.yaml"
+
+// CatalogVersion is the newest manifest format this hbctl understands.
+const CatalogVersion = 1
+
+type catalogManifest struct {
+	Version  int                    `yaml:"version"`
+	Elements []catalogElement       `yaml:"elements"`
+	Units    map[string]catalogUnit `yaml:"units"`
+}
+
+type catalogElement struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Unit        string   `yaml:"unit"`
+	Aliases     []string `yaml:"aliases"`
+	Compose     []string `yaml:"compose"`
+	Enterprise  bool     `yaml:"enterprise"`
+	DependsOn   []string `yaml:"depends_on"`
+	Ready       string   `yaml:"ready"`
+	Hidden      bool     `yaml:"hidden"`
+}
+
+type catalogUnit struct {
+	Elements []string `yaml:"elements"`
+	Requires []string `yaml:"requires"`
+}
+
+// catalog holds the manifest-only lookups that have no built-in table here.
+// The built-in equivalents live next to the compose file constants in the
+// local package and are used whenever no manifest is loaded.
+type catalog struct {
+	path       string
+	version    int
+	order      []string
+	aliases    map[string]string
+	compose    map[string][]string
+	enterprise map[string]bool
+}
+
+var activeCatalog *catalog
+
+// FindCatalog returns the manifest path to load, or "" when hbctl should use
+// the built-in tables, and whether the path was asked for rather than
+// discovered. Priority:
+//  1. explicit path (the --catalog flag)
+//  2. HBCTL_CATALOG
+//  3. ./hbctl-catalog.yaml (running from the Herringbone docker directory)
+//  4. ./docker/hbctl-catalog.yaml (running from the Herringbone repo root)
+func FindCatalog(explicit string) (string, bool, error) {
+	explicit = strings.TrimSpace(explicit)
+	if explicit == "" {
+		explicit = strings.TrimSpace(os.Getenv("HBCTL_CATALOG"))
+	}
+	if explicit != "" {
+		if _, err := os.Stat(explicit); err != nil {
+			return "", true, fmt.Errorf("catalog manifest not found: %s", explicit)
+		}
+		return explicit, true, nil
+	}
+
+	for _, candidate := range []string{CatalogFileName, filepath.Join("docker", CatalogFileName)} {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, false, nil
+		}
+	}
+	return "", false, nil
+}
+
+// LoadCatalog parses a manifest and makes it the active catalog. AllElements,
+// UnitElements, ServiceUnits, ElementDependencies, and ElementReadiness are
+// replaced so every existing caller sees the manifest without further changes.
+func LoadCatalog(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var manifest catalogManifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("invalid catalog manifest %s: %w", path, err)
+	}
+	if manifest.Version <= 0 {
+		return fmt.Errorf("catalog manifest %s is missing version", path)
+	}
+	if manifest.Version > CatalogVersion {
+		return fmt.Errorf("catalog manifest %s uses version %d; this hbctl supports up to version %d", path, manifest.Version, CatalogVersion)
+	}
+	if len(manifest.Elements) == 0 {
+		return fmt.Errorf("catalog manifest %s declares no elements", path)
+	}
+
+	loaded := &catalog{
+		path:       path,
+		version:    manifest.Version,
+		aliases:    map[string]string{},
+		compose:    map[string][]string{},
+		enterprise: map[string]bool{},
+	}
+	elements := []ElementInfo{}
+	unitElements := map[string][]string{}
+	dependencies := map[string][]string{}
+	readiness := map[string]string{}
+
+	for _, element := range manifest.Elements {
+		name := strings.TrimSpace(element.Name)
+		if name == "" {
+			return fmt.Errorf("catalog manifest %s has an element without a name", path)
+		}
+		if _, exists := loaded.compose[name]; exists {
+			return fmt.Errorf("catalog manifest %s declares element %s more than once", path, name)
+		}
+		unit := strings.TrimSpace(element.Unit)
+		if unit == "" {
+			return fmt.Errorf("catalog element %s has no unit", name)
+		}
+		files := trimmedStrings(element.Compose)
+		if len(files) == 0 {
+			return fmt.Errorf("catalog element %s declares no compose files", name)
+		}
+
+		if ready := strings.TrimSpace(element.Ready); ready != "" {
+			if _, err := ParseReadinessProbe(ready); err != nil {
+				return fmt.Errorf("catalog element %s: %w", name, err)
+			}
+			readiness[name] = ready
+		}
+
+		loaded.order = append(loaded.order, name)
+		loaded.compose[name] = files
+		loaded.enterprise[name] = element.Enterprise
+		dependencies[name] = trimmedStrings(element.DependsOn)
+		unitElements[unit] = append(unitElements[unit], name)
+		if !element.Hidden {
+			elements = append(elements, ElementInfo{Name: name, Description: strings.TrimSpace(element.Description), Unit: unit})
+		}
+
+		for _, alias := range append([]string{name}, element.Aliases...) {
+			alias = strings.TrimSpace(alias)
+			if alias == "" {
+				continue
+			}
+			if owner, exists := loaded.aliases[alias]; exists && owner != name {
+				return fmt.Errorf("catalog alias %q is claimed by both %s and %s", alias, owner, name)
+			}
+			loaded.aliases[alias] = name
+		}
+	}
+
+	for name, deps := range dependencies {
+		for _, dep := range deps {
+			if _, ok := loaded.compose[dep]; !ok {
+				return fmt.Errorf("catalog element %s depends on unknown element %s", name, dep)
+			}
+		}
+	}
+
+	serviceUnits := map[string][]string{}
+	for unit, declared := range manifest.Units {
+		unit = strings.TrimSpace(unit)
+		members := trimmedStrings(declared.Elements)
+		if len(members) == 0 {
+			members = unitElements[unit]
+		}
+		for _, member := range append(append([]string{}, members...), declared.Requires...) {
+			if _, ok := loaded.compose[strings.TrimSpace(member)]; !ok {
+				return fmt.Errorf("catalog unit %s references unknown element %s", unit, member)
+			}
+		}
+		unitElements[unit] = members
+		serviceUnits[unit] = uniqueStrings(append(append([]string{}, members...), trimmedStrings(declared.Requires)...))
+	}
+	for unit, members := range unitElements {
+		if _, ok := serviceUnits[unit]; ok {
+			continue
+		}
+		serviceUnits[unit] = uniqueStrings(append(append([]string{}, members...), dependencyClosure(members, dependencies)...))
+	}
+
+	activeCatalog = loaded
+	AllElements = elements
+	UnitElements = unitElements
+	ServiceUnits = serviceUnits
+	ElementDependencies = dependencies
+	ElementReadiness = readiness
+	return nil
+}
+
+// CatalogSource describes where the active element catalog came from.
+func CatalogSource() string {
+	if activeCatalog == nil {
+		return "built-in"
+	}
+	return fmt.Sprintf("%s (version %d)", activeCatalog.path, activeCatalog.version)
+}
+
+// HasCatalog reports whether a manifest replaced the built-in tables.
+func HasCatalog() bool {
+	return activeCatalog != nil
+}
+
+// ResolveAlias maps a manifest alias to its element name.
+func ResolveAlias(name string) (string, bool) {
+	if activeCatalog == nil {
+		return "", false
+	}
+	resolved, ok := activeCatalog.aliases[strings.TrimSpace(name)]
+	return resolved, ok
+}
+
+// ComposeFiles returns the manifest compose files for an element.
+func ComposeFiles(element string) ([]string, bool) {
+	if activeCatalog == nil {
+		return nil, false
+	}
+	files, ok := activeCatalog.compose[strings.TrimSpace(element)]
+	return append([]string{}, files...), ok
+}
+
+// IsEnterprise returns the manifest enterprise-only flag for an element.
+func IsEnterprise(element string) (bool, bool) {
+	if activeCatalog == nil {
+		return false, false
+	}
+	enterprise, ok := activeCatalog.enterprise[strings.TrimSpace(element)]
+	return enterprise, ok
+}
+
+// CatalogElementNames returns every manifest element in declaration order,
+// including hidden core elements.
+func CatalogElementNames() []string {
+	if activeCatalog == nil {
+		return nil
+	}
+	return append([]string{}, activeCatalog.order...)
+}
+
+func dependencyClosure(roots []string, dependencies map[string][]string) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	var visit func(string)
+	visit = func(name string) {
+		for _, dep := range dependencies[name] {
+			if seen[dep] {
+				continue
+			}
+			seen[dep] = true
+			visit(dep)
+			out = append(out, dep)
+		}
+	}
+	for _, root := range roots {
+		visit(root)
+	}
+	return out
+}
+
+func trimmedStrings(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			out = append(out, value)
+		}
+	}
+	return out
+}
+
+func uniqueStrings(values []string) []string {
+	out := make([]string, 0, len(values))
+	seen := map[string]bool{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		out = append(out, value)
+	}
+	return out
+}
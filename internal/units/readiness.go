@@ -0,0 +1,131 @@
+package units
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Readiness probe kinds. A probe spec is written as "docker", "mongo",
+// "none", "http:<path>[#<statuses>]" (requested through the proxy), or
+// "tcp:<[host:]port>". HTTP statuses are a comma-separated list of codes or
+// classes such as 2xx, and default to 2xx,3xx.
+const (
+	ProbeDocker = "docker"
+	ProbeHTTP   = "http"
+	ProbeTCP    = "tcp"
+	ProbeMongo  = "mongo"
+	ProbeNone   = "none"
+)
+
+// ReadinessProbe describes how hbctl decides that an element is ready.
+type ReadinessProbe struct {
+	Kind   string
+	Target string
+	// Status lists the HTTP statuses that count as ready; empty means 2xx,3xx.
+	Status []string
+}
+
+var defaultHTTPStatus = []string{"2xx", "3xx"}
+
+func (p ReadinessProbe) String() string {
+	if p.Target == "" {
+		return p.Kind
+	}
+	if len(p.Status) > 0 {
+		return p.Kind + ":" + p.Target + "#" + strings.Join(p.Status, ",")
+	}
+	return p.Kind + ":" + p.Target
+}
+
+// AcceptsStatus reports whether an HTTP probe treats code as ready.
+func (p ReadinessProbe) AcceptsStatus(code int) bool {
+	statuses := p.Status
+	if len(statuses) == 0 {
+		statuses = defaultHTTPStatus
+	}
+	for _, status := range statuses {
+		if strings.HasSuffix(status, "xx") {
+			if code/100 == int(status[0]-'0') {
+				return true
+			}
+		} else if strconv.Itoa(code) == status {
+			return true
+		}
+	}
+	return false
+}
+
+// ElementReadiness lists readiness probes for elements that need more than the
+// default Docker container state/healthcheck probe. The proxy is ready once it
+// answers at all; the routes behind it may not serve "/".
+var ElementReadiness = map[string]string{
+	"mongodb":           ProbeMongo,
+	"herringbone-proxy": "http:/#2xx,3xx,4xx",
+	"herringbone-auth":  "http:/health",
+}
+
+// ParseReadinessProbe parses a probe spec. An empty spec is the Docker probe.
+func ParseReadinessProbe(spec string) (ReadinessProbe, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return ReadinessProbe{Kind: ProbeDocker}, nil
+	}
+	kind, target, _ := strings.Cut(spec, ":")
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	target = strings.TrimSpace(target)
+
+	switch kind {
+	case ProbeDocker, ProbeMongo, ProbeNone:
+		if target != "" {
+			return ReadinessProbe{}, fmt.Errorf("readiness probe %q takes no target", kind)
+		}
+	case ProbeHTTP:
+		path, statuses, hasStatus := strings.Cut(target, "#")
+		target = strings.TrimSpace(path)
+		if target == "" {
+			target = "/"
+		}
+		if !strings.HasPrefix(target, "/") {
+			target = "/" + target
+		}
+		var status []string
+		if hasStatus {
+			for _, code := range strings.Split(statuses, ",") {
+				code = strings.ToLower(strings.TrimSpace(code))
+				if !validHTTPStatus(code) {
+					return ReadinessProbe{}, fmt.Errorf("invalid http status %q in readiness probe %q; use codes such as 200 or classes such as 2xx", code, spec)
+				}
+				status = append(status, code)
+			}
+		}
+		return ReadinessProbe{Kind: kind, Target: target, Status: status}, nil
+	case ProbeTCP:
+		if target == "" {
+			return ReadinessProbe{}, fmt.Errorf("tcp readiness probe needs a port, e.g. tcp:27017")
+		}
+	default:
+		return ReadinessProbe{}, fmt.Errorf("unknown readiness probe %q; use docker, http:<path>, tcp:<port>, mongo, or none", spec)
+	}
+	return ReadinessProbe{Kind: kind, Target: target}, nil
+}
+
+func validHTTPStatus(code string) bool {
+	if len(code) != 3 || code[0] < '1' || code[0] > '5' {
+		return false
+	}
+	if code[1:] == "xx" {
+		return true
+	}
+	n, err := strconv.Atoi(code)
+	return err == nil && n >= 100 && n <= 599
+}
+
+// ReadinessFor returns the readiness probe for an element.
+func ReadinessFor(element string) ReadinessProbe {
+	probe, err := ParseReadinessProbe(ElementReadiness[strings.TrimSpace(element)])
+	if err != nil {
+		return ReadinessProbe{Kind: ProbeDocker}
+	}
+	return probe
+}